@@ -0,0 +1,212 @@
+// Package assets proxies icons and renders from Blizzard CDN, so the panel loads images from the extension backend only
+package assets
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/salmondx/wow-twitch-extension/model"
+)
+
+// Pattern is a route of the proxy. Assets are served by upstream host and path, like /assets/render-eu.worldofwarcraft.com/icons/36/inv_sword_04.jpg
+const Pattern = "/assets/{host}/{path...}"
+
+// DefaultMaxSize limits a single asset. Full character renders are the largest ones
+const DefaultMaxSize = 4 << 20
+
+// Assets at the same URL never change, so browsers may keep them for a week
+const maxAge = 7 * 24 * time.Hour
+
+const fetchTimeout = 10 * time.Second
+
+// contentTypes are accepted image types, detected from content instead of upstream headers
+var contentTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// Store keeps fetched assets. GetAsset returns nil without error for missing assets
+type Store interface {
+	GetAsset(ctx context.Context, key string) ([]byte, error)
+	PutAsset(ctx context.Context, key string, data []byte) error
+}
+
+// AllowedHost checks that host is a Blizzard render CDN, so the proxy can't be used for arbitrary sites
+func AllowedHost(host string) bool {
+	if host == "render.worldofwarcraft.com" {
+		return true
+	}
+	for _, region := range model.Regions {
+		if host == "render-"+string(region)+".worldofwarcraft.com" {
+			return true
+		}
+	}
+	return false
+}
+
+// Rewriter rewrites CDN URLs to URLs of the proxy
+type Rewriter struct {
+	// BaseURL is a public URL of the backend, like https://example.com
+	BaseURL string
+}
+
+// Rewrite returns proxy URL of an asset. URLs of other hosts are returned as is
+func (r Rewriter) Rewrite(assetURL string) string {
+	parsed, err := url.Parse(assetURL)
+	if err != nil || parsed.Scheme != "https" || !AllowedHost(parsed.Host) || parsed.RawQuery != "" {
+		return assetURL
+	}
+	return strings.TrimSuffix(r.BaseURL, "/") + "/assets/" + parsed.Host + parsed.EscapedPath()
+}
+
+// Proxy serves assets from store, fetching missing ones from CDN. Concurrent requests of a missing asset share one fetch
+type Proxy struct {
+	store      Store
+	httpClient *http.Client
+	maxSize    int64
+
+	mu       sync.Mutex
+	fetching map[string]*fetchCall
+}
+
+// fetchCall is a fetch of an asset shared by concurrent requests
+type fetchCall struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+// NewProxy creates proxy keeping assets in store. Assets larger than maxSize are rejected
+func NewProxy(store Store, maxSize int64) *Proxy {
+	return &Proxy{
+		store:      store,
+		httpClient: &http.Client{Timeout: fetchTimeout},
+		maxSize:    maxSize,
+		fetching:   make(map[string]*fetchCall),
+	}
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	host := r.PathValue("host")
+	if !AllowedHost(host) {
+		http.NotFound(w, r)
+		return
+	}
+	assetURL := "https://" + host + "/" + r.PathValue("path")
+	data, err := p.asset(r.Context(), assetURL)
+	if err != nil {
+		log.Printf("[WARN] Can't proxy %s. %v", assetURL, err)
+		http.Error(w, "Asset is unavailable", http.StatusBadGateway)
+		return
+	}
+	if data == nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", http.DetectContentType(data))
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+}
+
+// asset returns asset from store or CDN. Nil is returned for assets missing on CDN
+func (p *Proxy) asset(ctx context.Context, assetURL string) ([]byte, error) {
+	key := assetKey(assetURL)
+	data, err := p.store.GetAsset(ctx, key)
+	if err != nil {
+		log.Printf("[WARN] Can't read asset %s from store. %v", assetURL, err)
+	}
+	if data != nil {
+		return data, nil
+	}
+
+	p.mu.Lock()
+	call, ok := p.fetching[key]
+	if !ok {
+		call = &fetchCall{done: make(chan struct{})}
+		p.fetching[key] = call
+		// fetch is shared, so it must outlive the viewer who started it
+		go p.fetchShared(context.WithoutCancel(ctx), key, assetURL, call)
+	}
+	p.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.data, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// fetchShared fetches and stores an asset for all requests waiting for call
+func (p *Proxy) fetchShared(ctx context.Context, key, assetURL string, call *fetchCall) {
+	defer func() {
+		p.mu.Lock()
+		delete(p.fetching, key)
+		p.mu.Unlock()
+		close(call.done)
+	}()
+	call.data, call.err = p.fetch(ctx, assetURL)
+	if call.err != nil || call.data == nil {
+		return
+	}
+	if err := p.store.PutAsset(ctx, key, call.data); err != nil {
+		log.Printf("[WARN] Can't save asset %s to store. %v", assetURL, err)
+	}
+}
+
+// fetch downloads and validates an asset
+func (p *Proxy) fetch(ctx context.Context, assetURL string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, assetURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusForbidden:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("Unexpected status %d", resp.StatusCode)
+	}
+	if resp.ContentLength > p.maxSize {
+		return nil, fmt.Errorf("Asset is too large: %d bytes", resp.ContentLength)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, p.maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > p.maxSize {
+		return nil, fmt.Errorf("Asset is larger than %d bytes", p.maxSize)
+	}
+	if contentType := http.DetectContentType(data); !contentTypes[contentType] {
+		return nil, fmt.Errorf("Asset is not an image: %s", contentType)
+	}
+	return data, nil
+}
+
+func assetKey(assetURL string) string {
+	sum := sha256.Sum256([]byte(assetURL))
+	return hex.EncodeToString(sum[:])
+}
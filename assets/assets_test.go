@@ -0,0 +1,152 @@
+package assets
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// pngHeader is enough for content type detection
+var pngHeader = []byte("\x89PNG\x0D\x0A\x1A\x0A\x00\x00\x00\x0DIHDR")
+
+type memoryStore map[string][]byte
+
+func (m memoryStore) GetAsset(ctx context.Context, key string) ([]byte, error) {
+	return m[key], nil
+}
+
+func (m memoryStore) PutAsset(ctx context.Context, key string, data []byte) error {
+	m[key] = data
+	return nil
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestRewrite(t *testing.T) {
+	rewriter := Rewriter{BaseURL: "https://ext.example.com/"}
+	var tests = []struct {
+		url      string
+		expected string
+	}{
+		{"https://render-eu.worldofwarcraft.com/icons/36/inv_sword_04.jpg", "https://ext.example.com/assets/render-eu.worldofwarcraft.com/icons/36/inv_sword_04.jpg"},
+		{"https://render.worldofwarcraft.com/eu/character/soulflayer/51/64174899-main-raw.png", "https://ext.example.com/assets/render.worldofwarcraft.com/eu/character/soulflayer/51/64174899-main-raw.png"},
+		{"https://render-eu.worldofwarcraft.com.example.org/icon.jpg", "https://render-eu.worldofwarcraft.com.example.org/icon.jpg"},
+		{"https://wow.zamimg.com/images/wow/icons/large/inv_sword_04.jpg", "https://wow.zamimg.com/images/wow/icons/large/inv_sword_04.jpg"},
+		{"", ""},
+	}
+	for _, test := range tests {
+		if actual := rewriter.Rewrite(test.url); actual != test.expected {
+			t.Errorf("Rewrite(%q) = %q, expected %q", test.url, actual, test.expected)
+		}
+	}
+}
+
+func TestProxy(t *testing.T) {
+	upstream := map[string][]byte{
+		"https://render-eu.worldofwarcraft.com/icons/36/inv_sword_04.jpg": pngHeader,
+		"https://render-eu.worldofwarcraft.com/icons/36/large.jpg":        append(pngHeader, make([]byte, 64)...),
+		"https://render-eu.worldofwarcraft.com/icons/36/page.jpg":         []byte("<html><body>Maintenance</body></html>"),
+	}
+	fetched := 0
+	store := memoryStore{}
+	proxy := NewProxy(store, 32)
+	proxy.httpClient.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		fetched++
+		data, ok := upstream[r.URL.String()]
+		if !ok {
+			return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader(""))}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, ContentLength: -1, Body: io.NopCloser(bytes.NewReader(data))}, nil
+	})
+	mux := http.NewServeMux()
+	mux.Handle(Pattern, proxy)
+
+	var tests = []struct {
+		path   string
+		status int
+	}{
+		{"/assets/render-eu.worldofwarcraft.com/icons/36/inv_sword_04.jpg", http.StatusOK},
+		{"/assets/render-eu.worldofwarcraft.com/icons/36/inv_sword_04.jpg", http.StatusOK},
+		{"/assets/render-eu.worldofwarcraft.com/icons/36/missing.jpg", http.StatusNotFound},
+		{"/assets/render-eu.worldofwarcraft.com/icons/36/large.jpg", http.StatusBadGateway},
+		{"/assets/render-eu.worldofwarcraft.com/icons/36/page.jpg", http.StatusBadGateway},
+		{"/assets/example.org/icons/36/inv_sword_04.jpg", http.StatusNotFound},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+		if w.Code != test.status {
+			t.Errorf("%s: status %d, expected %d", test.path, w.Code, test.status)
+		}
+		if w.Code == http.StatusOK && w.Header().Get("Content-Type") != "image/png" {
+			t.Errorf("%s: wrong content type %s", test.path, w.Header().Get("Content-Type"))
+		}
+	}
+	// cached icon is fetched once, disallowed host is never fetched
+	if fetched != 4 {
+		t.Errorf("Expected 4 upstream requests, got %d", fetched)
+	}
+	if len(store) != 1 {
+		t.Errorf("Expected only valid asset to be stored, got %d", len(store))
+	}
+}
+
+func TestProxySharesFetch(t *testing.T) {
+	release := make(chan struct{})
+	var fetched int32
+	proxy := NewProxy(&syncStore{store: memoryStore{}}, DefaultMaxSize)
+	proxy.httpClient.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		atomic.AddInt32(&fetched, 1)
+		<-release
+		return &http.Response{StatusCode: http.StatusOK, ContentLength: -1, Body: io.NopCloser(bytes.NewReader(pngHeader))}, nil
+	})
+
+	const requests = 5
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data, err := proxy.asset(context.Background(), "https://render-eu.worldofwarcraft.com/icons/36/inv_sword_04.jpg")
+			if err != nil || !bytes.Equal(data, pngHeader) {
+				t.Errorf("Shared fetch returned %v, %v", data, err)
+			}
+		}()
+	}
+	// let all requests miss the store and wait for the fetch
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if atomic.LoadInt32(&fetched) != 1 {
+		t.Errorf("Expected 1 upstream request, got %d", fetched)
+	}
+}
+
+// syncStore guards memoryStore used by concurrent requests
+type syncStore struct {
+	mu    sync.Mutex
+	store memoryStore
+}
+
+func (s *syncStore) GetAsset(ctx context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.store.GetAsset(ctx, key)
+}
+
+func (s *syncStore) PutAsset(ctx context.Context, key string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.store.PutAsset(ctx, key, data)
+}
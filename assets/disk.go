@@ -0,0 +1,128 @@
+package assets
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultDiskLimit limits total size of assets kept on disk
+const DefaultDiskLimit = 512 << 20
+
+// DiskStore keeps assets as files in a directory. Least recently used files are removed
+// when total size exceeds the limit
+type DiskStore struct {
+	dir   string
+	limit int64
+
+	mu   sync.Mutex
+	size int64
+}
+
+// NewDiskStore creates directory if needed and counts assets already kept there
+func NewDiskStore(dir string, limit int64) (*DiskStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	store := &DiskStore{dir: dir, limit: limit}
+	files, err := store.files()
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		store.size += file.Size()
+	}
+	return store, nil
+}
+
+func (s *DiskStore) GetAsset(ctx context.Context, key string) ([]byte, error) {
+	path := filepath.Join(s.dir, key)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// modification time marks recent use, so popular assets survive eviction
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return data, nil
+}
+
+func (s *DiskStore) PutAsset(ctx context.Context, key string, data []byte) error {
+	path := filepath.Join(s.dir, key)
+	// asset is renamed into place, so readers never see a partial file
+	tmp, err := os.CreateTemp(s.dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	previous, statErr := os.Stat(path)
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.size += int64(len(data))
+	if statErr == nil {
+		s.size -= previous.Size()
+	}
+	if s.size > s.limit {
+		return s.evict()
+	}
+	return nil
+}
+
+// evict removes least recently used assets until total size is below 90% of the limit
+func (s *DiskStore) evict() error {
+	files, err := s.files()
+	if err != nil {
+		return err
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().Before(files[j].ModTime())
+	})
+	target := s.limit / 10 * 9
+	for _, file := range files {
+		if s.size <= target {
+			break
+		}
+		if err := os.Remove(filepath.Join(s.dir, file.Name())); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		s.size -= file.Size()
+	}
+	return nil
+}
+
+func (s *DiskStore) files() ([]os.FileInfo, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || filepath.Ext(entry.Name()) == ".tmp" {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, info)
+	}
+	return files, nil
+}
@@ -0,0 +1,42 @@
+package assets
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDiskStoreEviction(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewDiskStore(dir, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for i, key := range []string{"old", "used"} {
+		if err := store.PutAsset(ctx, key, make([]byte, 40)); err != nil {
+			t.Fatal(err)
+		}
+		past := time.Now().Add(time.Duration(i-10) * time.Minute)
+		os.Chtimes(filepath.Join(dir, key), past, past)
+	}
+	// reading marks asset as recently used
+	if _, err := store.GetAsset(ctx, "used"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.PutAsset(ctx, "new", make([]byte, 40)); err != nil {
+		t.Fatal(err)
+	}
+
+	for key, kept := range map[string]bool{"old": false, "used": true, "new": true} {
+		data, err := store.GetAsset(ctx, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (data != nil) != kept {
+			t.Errorf("%s: kept %v, expected %v", key, data != nil, kept)
+		}
+	}
+}
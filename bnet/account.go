@@ -0,0 +1,99 @@
+package bnet
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/salmondx/wow-twitch-extension/model"
+)
+
+// scope of user tokens, grants access to WoW characters of the account
+const accountScope = "wow.profile"
+
+const (
+	authorizeURL      = "https://%s/authorize?%s"
+	accountProfileURL = "https://%s/profile/user/wow?namespace=%s&locale=%s"
+)
+
+// AccountCharacter is a character of a Battle.Net account. Gender and faction types are like FEMALE and HORDE
+type AccountCharacter struct {
+	Name          string  `json:"name"`
+	ID            int     `json:"id"`
+	Realm         Realm   `json:"realm"`
+	PlayableClass Ref     `json:"playable_class"`
+	PlayableRace  Ref     `json:"playable_race"`
+	Gender        TypeRef `json:"gender"`
+	Faction       TypeRef `json:"faction"`
+	Level         int     `json:"level"`
+}
+
+// WowAccount is a WoW license of a Battle.Net account
+type WowAccount struct {
+	ID         int                `json:"id"`
+	Characters []AccountCharacter `json:"characters"`
+}
+
+// AccountProfile is a WoW profile summary of a Battle.Net account. Account may have several WoW licenses
+type AccountProfile struct {
+	WowAccounts []WowAccount `json:"wow_accounts"`
+}
+
+// AuthorizeURL returns Battle.Net page where account owner grants access to their characters.
+// Battle.Net redirects back to redirectURI with authorization code and state
+func (c *Client) AuthorizeURL(region model.Region, state, redirectURI string) (string, error) {
+	info, err := lookupRegion(region)
+	if err != nil {
+		return "", err
+	}
+	query := url.Values{
+		"client_id":     {c.clientID},
+		"scope":         {accountScope},
+		"state":         {state},
+		"redirect_uri":  {redirectURI},
+		"response_type": {"code"},
+	}
+	return fmt.Sprintf(authorizeURL, info.oauthHost, query.Encode()), nil
+}
+
+// ExchangeCode exchanges authorization code for a user access token. Redirect URI must match the authorization one
+func (c *Client) ExchangeCode(ctx context.Context, region model.Region, code, redirectURI string) (string, error) {
+	if c.clientID == "" {
+		return "", errNoClientID
+	}
+	info, err := lookupRegion(region)
+	if err != nil {
+		return "", err
+	}
+	grant, err := c.requestToken(ctx, info.oauthHost, url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {redirectURI},
+		"scope":        {accountScope},
+	})
+	if err != nil {
+		return "", err
+	}
+	return grant.AccessToken, nil
+}
+
+// GetAccountProfile retrieves characters of the owner of user access token
+func (c *Client) GetAccountProfile(ctx context.Context, region model.Region, token string) (*AccountProfile, error) {
+	info, err := lookupRegion(region)
+	if err != nil {
+		return nil, err
+	}
+	resource := fmt.Sprintf(accountProfileURL, info.apiHost, Namespace(NamespaceProfile, region, false), info.locale)
+	var profile AccountProfile
+	err = c.getJSONWithToken(ctx, region, resource, token, &profile)
+	if err == errUnauthorized {
+		return nil, model.ForbiddenError{"Battle.Net account access is not granted"}
+	}
+	if err == errNotFound {
+		return nil, model.CharacterNotFound{"No WoW characters on the Battle.Net account"}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
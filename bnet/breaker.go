@@ -0,0 +1,104 @@
+package bnet
+
+import (
+	"sync"
+	"time"
+)
+
+// BreakerState is a state of a region circuit breaker
+type BreakerState string
+
+const (
+	// BreakerClosed lets every request through
+	BreakerClosed BreakerState = "closed"
+	// BreakerOpen rejects requests without calling Battle.Net
+	BreakerOpen BreakerState = "open"
+	// BreakerHalfOpen lets a single probe request through after cooldown
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+const (
+	breakerFailureThreshold = 5
+	breakerCooldown         = 30 * time.Second
+)
+
+// breaker trips after a number of consecutive failures and stays open until cooldown passes.
+// After cooldown only one probe request is allowed, its result closes or reopens the breaker
+type breaker struct {
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	now      func() time.Time
+}
+
+func newBreaker() *breaker {
+	return &breaker{state: BreakerClosed, now: time.Now}
+}
+
+// allow reports whether a request may be sent
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Sub(b.openedAt) < breakerCooldown {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		return true
+	case BreakerHalfOpen:
+		// probe is already in flight
+		return false
+	}
+	return true
+}
+
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state = BreakerClosed
+	b.failures = 0
+}
+
+func (b *breaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= breakerFailureThreshold {
+		b.state = BreakerOpen
+		b.openedAt = b.now()
+	}
+}
+
+// retryAfter returns time left until the next probe is allowed
+func (b *breaker) retryAfter() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state != BreakerOpen {
+		return 0
+	}
+	left := breakerCooldown - b.now().Sub(b.openedAt)
+	if left < 0 {
+		return 0
+	}
+	return left
+}
+
+// release gives up a probe without a result, so the next request may probe again
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerHalfOpen {
+		b.state = BreakerOpen
+	}
+}
+
+func (b *breaker) current() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerOpen && b.now().Sub(b.openedAt) >= breakerCooldown {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
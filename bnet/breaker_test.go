@@ -0,0 +1,66 @@
+package bnet
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	now := time.Now()
+	b := newBreaker()
+	b.now = func() time.Time { return now }
+
+	for i := 0; i < breakerFailureThreshold-1; i++ {
+		b.failure()
+	}
+	if !b.allow() || b.current() != BreakerClosed {
+		t.Fatalf("Breaker opened before threshold")
+	}
+	b.failure()
+	if b.allow() || b.current() != BreakerOpen {
+		t.Fatalf("Breaker not opened after threshold")
+	}
+
+	now = now.Add(breakerCooldown)
+	if !b.allow() {
+		t.Fatalf("Probe not allowed after cooldown")
+	}
+	if b.allow() {
+		t.Errorf("Second probe allowed while half-open")
+	}
+	b.failure()
+	if b.current() != BreakerOpen {
+		t.Errorf("Failed probe must reopen breaker")
+	}
+
+	now = now.Add(breakerCooldown)
+	b.allow()
+	b.release()
+	if !b.allow() {
+		t.Fatalf("Released probe must allow a new one")
+	}
+	b.success()
+	if b.current() != BreakerClosed || !b.allow() {
+		t.Errorf("Successful probe must close breaker")
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	var tests = []struct {
+		in    string
+		delay time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"3", 3 * time.Second, true},
+		{"soon", 0, false},
+		{time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), 0, true},
+	}
+	for _, tt := range tests {
+		delay, ok := retryAfter(tt.in)
+		if delay != tt.delay || ok != tt.ok {
+			t.Errorf("Retry-After %q: got %v %v", tt.in, delay, ok)
+		}
+	}
+}
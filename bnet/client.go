@@ -1,8 +1,14 @@
 package bnet
 
+import "context"
 import "net/http"
 import "fmt"
 import "encoding/json"
+import "errors"
+import "io"
+import "io/ioutil"
+import "sync"
+import "time"
 import "github.com/salmondx/wow-twitch-extension/model"
 
 type Item struct {
@@ -10,9 +16,18 @@ type Item struct {
 	Name         string
 	ItemLevel    int
 	Icon         string
+	Quality      int
+	Armor        int
+	Stats        []ItemStat
+	BonusLists   []int
 	Enchantments Enchantments `json:"tooltipParams"`
 }
 
+type ItemStat struct {
+	Stat   int
+	Amount int
+}
+
 type Enchantments struct {
 	Gem0        int
 	Gem1        int
@@ -20,27 +35,30 @@ type Enchantments struct {
 	Gem3        int
 	Gem4        int
 	Enchantment int
+	// Set lists equipped items of the same item set
+	Set []int
 }
 
+// Items are equipped items. Slot tag is a stable key of the equipment slot
 type Items struct {
-	AverageItemLevelEquipped int `json:"averageItemLevelEquipped,omitempty"`
-	Head                     Item
-	Neck                     Item
-	Shoulder                 Item
-	Back                     Item
-	Chest                    Item
-	Tabard                   Item
-	Wrist                    Item
-	Hands                    Item
-	Waist                    Item
-	Legs                     Item
-	Feet                     Item
-	Finger1                  Item
-	Finger2                  Item
-	Trinket1                 Item
-	Trinket2                 Item
-	MainHand                 Item
-	OffHand                  Item
+	AverageItemLevelEquipped int  `json:"averageItemLevelEquipped,omitempty"`
+	Head                     Item `slot:"HEAD"`
+	Neck                     Item `slot:"NECK"`
+	Shoulder                 Item `slot:"SHOULDER"`
+	Back                     Item `slot:"BACK"`
+	Chest                    Item `slot:"CHEST"`
+	Tabard                   Item `slot:"TABARD"`
+	Wrist                    Item `slot:"WRIST"`
+	Hands                    Item `slot:"HANDS"`
+	Waist                    Item `slot:"WAIST"`
+	Legs                     Item `slot:"LEGS"`
+	Feet                     Item `slot:"FEET"`
+	Finger1                  Item `slot:"FINGER_1"`
+	Finger2                  Item `slot:"FINGER_2"`
+	Trinket1                 Item `slot:"TRINKET_1"`
+	Trinket2                 Item `slot:"TRINKET_2"`
+	MainHand                 Item `slot:"MAIN_HAND"`
+	OffHand                  Item `slot:"OFF_HAND"`
 }
 
 type Guild struct {
@@ -88,61 +106,230 @@ type ArenaRating struct {
 	Brackets Brackets
 }
 
+// Title name is a template, like "%s the Insane"
+type Title struct {
+	ID       int
+	Name     string
+	Selected bool
+}
+
+// Validators are Battle.Net response validators used to revalidate a previously retrieved profile
+type Validators struct {
+	ETag         string `json:",omitempty"`
+	LastModified string `json:",omitempty"`
+}
+
 type CharacterProfile struct {
-	Name        string
-	Realm       string
-	Region      string
-	Class       int
-	Level       int
-	Thumbnail   string
-	Guild       Guild
-	Items       Items
-	Talents     []SpecTalents
-	ArenaRating ArenaRating `json:"pvp"`
+	Validators Validators `json:"-"`
+	Locale     string     `json:"-"`
+	Name       string
+	Realm      string
+	Region     model.Region
+	Class      int
+	Race       int
+	Gender     int
+	Faction    int
+	Level      int
+	Thumbnail  string
+	Titles     []Title
+	// AchievementPoints are shared by all characters of the account
+	AchievementPoints int
+	Guild             Guild
+	Items             Items
+	Talents           []SpecTalents
+	ArenaRating       ArenaRating `json:"pvp"`
 }
 
 type Client struct {
-	secret string
+	clientID   string
+	secret     string
+	httpClient *http.Client
+	limiter    Limiter
+
+	mu       sync.Mutex
+	breakers map[model.Region]*breaker
+	// tokens are access tokens by OAuth host
+	tokens map[string]accessToken
+}
+
+const battleNetURL = "https://%s/wow/character/%s/%s?fields=talents,guild,items,pvp,titles&locale=%s&apikey=%s"
+
+// timeout of a single attempt, retries are bounded by the caller context
+const attemptTimeout = 5 * time.Second
+
+// New creates a new Battle.Net client. Every request, including retries, waits for limiter first.
+// Game Data and Profile APIs are available only with client id
+func New(clientID, secret string, limiter Limiter) *Client {
+	return &Client{
+		clientID:   clientID,
+		secret:     secret,
+		httpClient: &http.Client{Timeout: attemptTimeout},
+		limiter:    limiter,
+		breakers:   make(map[model.Region]*breaker),
+		tokens:     make(map[string]accessToken),
+	}
 }
 
-const battleNetURL = "https://%s.api.battle.net/wow/character/%s/%s?fields=talents,guild,items,pvp&locale=%s&apikey=%s"
+// BreakerStates returns circuit breaker state of every region that was queried so far
+func (c *Client) BreakerStates() map[model.Region]BreakerState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	states := make(map[model.Region]BreakerState, len(c.breakers))
+	for region, b := range c.breakers {
+		states[region] = b.current()
+	}
+	return states
+}
 
-// New creates a new Battle.Net client
-func New(secret string) *Client {
-	return &Client{secret}
+func (c *Client) breaker(region model.Region) *breaker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, ok := c.breakers[region]
+	if !ok {
+		b = newBreaker()
+		c.breakers[region] = b
+	}
+	return b
+}
+
+// get performs idempotent GET request with given headers, retrying throttled, failed and timed out attempts with backoff.
+// Region breaker rejects requests without calling Battle.Net while the region is failing
+func (c *Client) get(ctx context.Context, region model.Region, url string, header http.Header) (*http.Response, error) {
+	b := c.breaker(region)
+	if !b.allow() {
+		return nil, model.UpstreamUnavailableError{
+			S:          fmt.Sprintf("Battle.Net API for region %s is unavailable", region),
+			RetryAfter: b.retryAfter(),
+		}
+	}
+
+	var resp *http.Response
+	var err error
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if attempt > 0 {
+			delay := backoff(attempt-1, resp)
+			// no point in waiting longer than the caller is ready to
+			if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+				break
+			}
+			if resp != nil {
+				drain(resp)
+			}
+			select {
+			case <-ctx.Done():
+				b.release()
+				return nil, ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+
+		if err = c.limiter.Wait(ctx, priorityFrom(ctx)); err != nil {
+			b.release()
+			return nil, err
+		}
+
+		var req *http.Request
+		req, err = http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			b.release()
+			return nil, err
+		}
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		resp, err = c.httpClient.Do(req.WithContext(ctx))
+		if ctx.Err() != nil {
+			// caller gave up, says nothing about Battle.Net health
+			if resp != nil {
+				drain(resp)
+			}
+			b.release()
+			return nil, ctx.Err()
+		}
+		if err == nil && !retryable(resp.StatusCode) {
+			b.success()
+			return resp, nil
+		}
+	}
+	b.failure()
+	return resp, err
+}
+
+// statusError classifies unexpected Battle.Net response status
+func statusError(resp *http.Response) error {
+	delay, _ := retryAfter(resp.Header.Get("Retry-After"))
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return model.RateLimitedError{S: "Battle.Net API quota exceeded", RetryAfter: delay}
+	}
+	if resp.StatusCode >= 500 {
+		return model.UpstreamUnavailableError{
+			S:          fmt.Sprintf("Battle.Net API is unavailable, status %d", resp.StatusCode),
+			RetryAfter: delay,
+		}
+	}
+	return fmt.Errorf("Invalid return code: %d", resp.StatusCode)
+}
+
+func drain(resp *http.Response) {
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
 }
 
 // GetCharacterProfile retrieves character profile from Battle.Net API by character name and realm
-// If not found, then error is thrown
-func (c *Client) GetCharacterProfile(region, realm, name string) (*CharacterProfile, error) {
-	resp, err := http.Get(fmt.Sprintf(battleNetURL, region, realm, name, locale(region), c.secret))
+// If not found, then error is thrown. The request is aborted when ctx is cancelled
+func (c *Client) GetCharacterProfile(ctx context.Context, region model.Region, realm, name string) (*CharacterProfile, error) {
+	profile, _, err := c.GetCharacterProfileIfModified(ctx, region, realm, name, "", Validators{})
+	return profile, err
+}
+
+// GetCharacterProfileIfModified retrieves character profile only if it was modified since validators were received.
+// If profile is not modified, nil profile is returned with modified set to false.
+// Names are localized with locale, empty locale stands for region default
+func (c *Client) GetCharacterProfileIfModified(ctx context.Context, region model.Region, realm, name, locale string, validators Validators) (*CharacterProfile, bool, error) {
+	header := make(http.Header)
+	if validators.ETag != "" {
+		header.Set("If-None-Match", validators.ETag)
+	}
+	if validators.LastModified != "" {
+		header.Set("If-Modified-Since", validators.LastModified)
+	}
+	info, err := lookupRegion(region)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to retrieve profile for %s - %s. Reason: %v", realm, name, err)
+		return nil, false, err
+	}
+	locale = ResolveLocale(region, locale)
+	resp, err := c.get(ctx, region, fmt.Sprintf(battleNetURL, info.host, realm, name, locale, c.secret), header)
+	if err != nil {
+		var unavailable model.UpstreamUnavailableError
+		if errors.As(err, &unavailable) || ctx.Err() != nil {
+			return nil, false, err
+		}
+		return nil, false, model.UpstreamUnavailableError{S: fmt.Sprintf("Failed to retrieve profile for %s - %s", realm, name), Err: err}
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, false, nil
+	}
 	if resp.StatusCode == 404 {
-		return nil, model.CharacterNotFound{fmt.Sprintf("Character not found: %s - %s", realm, name)}
+		return nil, false, model.CharacterNotFound{fmt.Sprintf("Character not found: %s - %s", realm, name)}
 	}
 	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("Invalid return code: %d", resp.StatusCode)
+		return nil, false, statusError(resp)
 	}
 	var characterProfile CharacterProfile
 
 	err = json.NewDecoder(resp.Body).Decode(&characterProfile)
 	if err != nil {
-		return nil, fmt.Errorf("Can't deserialize response: %v", err)
+		if ctx.Err() != nil {
+			return nil, false, ctx.Err()
+		}
+		return nil, false, fmt.Errorf("Can't deserialize response: %v", err)
 	}
 	characterProfile.Region = region
-	return &characterProfile, nil
-}
-
-func locale(region string) string {
-	if region == "us" {
-		return "en_US"
-	} else if region == "kr" {
-		return "ko_KR"
-	} else if region == "tw" {
-		return "zh_TR"
+	characterProfile.Locale = locale
+	characterProfile.Validators = Validators{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
 	}
-	return "en_GB"
+	return &characterProfile, true, nil
 }
@@ -0,0 +1,73 @@
+package bnet
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/salmondx/wow-twitch-extension/model"
+)
+
+// GuildProfile is a guild summary from Profile API. Faction type is ALLIANCE or HORDE
+type GuildProfile struct {
+	ID                int     `json:"id"`
+	Name              string  `json:"name"`
+	Faction           TypeRef `json:"faction"`
+	AchievementPoints int     `json:"achievement_points"`
+	MemberCount       int     `json:"member_count"`
+	Realm             Realm   `json:"realm"`
+}
+
+// GuildMember is a roster entry. Rank 0 is the guild master, rank names are not available in API
+type GuildMember struct {
+	Character struct {
+		Name          string `json:"name"`
+		ID            int    `json:"id"`
+		Realm         Realm  `json:"realm"`
+		Level         int    `json:"level"`
+		PlayableClass Ref    `json:"playable_class"`
+		PlayableRace  Ref    `json:"playable_race"`
+	} `json:"character"`
+	Rank int `json:"rank"`
+}
+
+type GuildRoster struct {
+	Members []GuildMember `json:"members"`
+}
+
+const (
+	guildURL       = "https://%s/data/wow/guild/%s/%s?namespace=%s&locale=%s"
+	guildRosterURL = "https://%s/data/wow/guild/%s/%s/roster?namespace=%s&locale=%s"
+)
+
+// GetGuild retrieves guild profile. Realm is a slug
+func (c *Client) GetGuild(ctx context.Context, region model.Region, realm, name, locale string) (*GuildProfile, error) {
+	var guild GuildProfile
+	if err := c.getGuildResource(ctx, guildURL, region, realm, name, locale, &guild); err != nil {
+		return nil, err
+	}
+	return &guild, nil
+}
+
+// GetGuildRoster retrieves members of a guild with their ranks. Realm is a slug
+func (c *Client) GetGuildRoster(ctx context.Context, region model.Region, realm, name, locale string) (*GuildRoster, error) {
+	var roster GuildRoster
+	if err := c.getGuildResource(ctx, guildRosterURL, region, realm, name, locale, &roster); err != nil {
+		return nil, err
+	}
+	return &roster, nil
+}
+
+func (c *Client) getGuildResource(ctx context.Context, resourceURL string, region model.Region, realm, name, locale string, v interface{}) error {
+	info, err := lookupRegion(region)
+	if err != nil {
+		return err
+	}
+	resource := fmt.Sprintf(resourceURL, info.apiHost, url.PathEscape(realm), url.PathEscape(model.Slug(name)),
+		Namespace(NamespaceProfile, region, false), ResolveLocale(region, locale))
+	err = c.getJSON(ctx, region, resource, v)
+	if err == errNotFound {
+		return model.GuildNotFound{fmt.Sprintf("Guild not found: %s - %s", realm, name)}
+	}
+	return err
+}
@@ -0,0 +1,65 @@
+package bnet
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/salmondx/wow-twitch-extension/model"
+)
+
+// SetBonus is an effect of an item set, active once threshold items are equipped
+type SetBonus struct {
+	Description string
+	Threshold   int
+}
+
+// ItemSet is a set of items, like a raid tier set
+type ItemSet struct {
+	ID         int
+	Name       string
+	SetBonuses []SetBonus
+	Items      []int
+}
+
+// ItemDetails is an item description, independent of a character
+type ItemDetails struct {
+	ID      int
+	Name    string
+	ItemSet ItemSet
+}
+
+const itemURL = "https://%s/wow/item/%d?locale=%s&apikey=%s"
+
+// GetItem retrieves item description with its item set. Names are localized with locale, empty locale stands for region default
+func (c *Client) GetItem(ctx context.Context, region model.Region, id int, locale string) (*ItemDetails, error) {
+	info, err := lookupRegion(region)
+	if err != nil {
+		return nil, err
+	}
+	locale = ResolveLocale(region, locale)
+	resp, err := c.get(ctx, region, fmt.Sprintf(itemURL, info.host, id, locale, c.secret), nil)
+	if err != nil {
+		var unavailable model.UpstreamUnavailableError
+		if errors.As(err, &unavailable) || ctx.Err() != nil {
+			return nil, err
+		}
+		return nil, model.UpstreamUnavailableError{S: fmt.Sprintf("Failed to retrieve item %d", id), Err: err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(resp)
+	}
+
+	var item ItemDetails
+	err = json.NewDecoder(resp.Body).Decode(&item)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("Can't deserialize item %d: %v", id, err)
+	}
+	return &item, nil
+}
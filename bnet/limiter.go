@@ -0,0 +1,132 @@
+package bnet
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Battle.Net API quotas per client
+const (
+	DefaultPerSecond = 100
+	DefaultPerHour   = 36000
+)
+
+// share of a quota that background requests can not use, so interactive requests are served first
+const interactiveReserve = 0.2
+
+// Priority of a Battle.Net request
+type Priority int
+
+const (
+	// PriorityInteractive is a request a viewer is waiting for, like a profile lookup
+	PriorityInteractive Priority = iota
+	// PriorityBackground is a request refreshing already known data, like a list refresh
+	PriorityBackground
+)
+
+type priorityKey struct{}
+
+// WithPriority returns a context that sends Battle.Net requests with given priority
+func WithPriority(ctx context.Context, priority Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+func priorityFrom(ctx context.Context) Priority {
+	if priority, ok := ctx.Value(priorityKey{}).(Priority); ok {
+		return priority
+	}
+	return PriorityInteractive
+}
+
+// Limiter keeps Battle.Net requests within API quotas
+type Limiter interface {
+	// Wait blocks until request with given priority may be sent or ctx is done
+	Wait(ctx context.Context, priority Priority) error
+}
+
+// bucket is a token bucket refilled continuously at rate tokens per second
+type bucket struct {
+	capacity float64
+	rate     float64
+	tokens   float64
+}
+
+func (b *bucket) refill(elapsed time.Duration) {
+	b.tokens += elapsed.Seconds() * b.rate
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+}
+
+// delay returns how long to wait until bucket has a token, keeping reserve untouched
+func (b *bucket) delay(reserve float64) time.Duration {
+	need := 1 + reserve*b.capacity
+	if b.tokens >= need {
+		return 0
+	}
+	return time.Duration((need - b.tokens) / b.rate * float64(time.Second))
+}
+
+// LocalLimiter is a Limiter for a single process with per second and per hour token buckets
+type LocalLimiter struct {
+	mu      sync.Mutex
+	buckets []*bucket
+	last    time.Time
+	now     func() time.Time
+}
+
+// NewLimiter creates a process wide limiter allowing perSecond and perHour requests
+func NewLimiter(perSecond, perHour int) *LocalLimiter {
+	return &LocalLimiter{
+		buckets: []*bucket{
+			{capacity: float64(perSecond), rate: float64(perSecond), tokens: float64(perSecond)},
+			{capacity: float64(perHour), rate: float64(perHour) / 3600, tokens: float64(perHour)},
+		},
+		last: time.Now(),
+		now:  time.Now,
+	}
+}
+
+func (l *LocalLimiter) Wait(ctx context.Context, priority Priority) error {
+	for {
+		delay := l.reserve(priority)
+		if delay == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// reserve takes a token from every bucket or returns time to wait before the next try
+func (l *LocalLimiter) reserve(priority Priority) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	elapsed := now.Sub(l.last)
+	l.last = now
+
+	var reserve float64
+	if priority == PriorityBackground {
+		reserve = interactiveReserve
+	}
+	var wait time.Duration
+	for _, b := range l.buckets {
+		b.refill(elapsed)
+		if delay := b.delay(reserve); delay > wait {
+			wait = delay
+		}
+	}
+	if wait > 0 {
+		return wait
+	}
+	for _, b := range l.buckets {
+		b.tokens--
+	}
+	return 0
+}
@@ -0,0 +1,53 @@
+package bnet
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiterPriority(t *testing.T) {
+	now := time.Now()
+	l := NewLimiter(10, 1000)
+	l.now = func() time.Time { return now }
+	l.last = now
+
+	// background requests leave 20% of the per second quota to interactive ones
+	for i := 0; i < 8; i++ {
+		if l.reserve(PriorityBackground) != 0 {
+			t.Fatalf("Background request %d was throttled", i)
+		}
+	}
+	if l.reserve(PriorityBackground) == 0 {
+		t.Fatalf("Background request used interactive reserve")
+	}
+	for i := 0; i < 2; i++ {
+		if l.reserve(PriorityInteractive) != 0 {
+			t.Fatalf("Interactive request %d was throttled", i)
+		}
+	}
+	delay := l.reserve(PriorityInteractive)
+	if delay <= 0 || delay > 100*time.Millisecond {
+		t.Fatalf("Wrong delay for empty bucket: %v", delay)
+	}
+
+	now = now.Add(delay)
+	if l.reserve(PriorityInteractive) != 0 {
+		t.Errorf("Bucket not refilled after delay")
+	}
+}
+
+func TestLimiterHourlyQuota(t *testing.T) {
+	now := time.Now()
+	l := NewLimiter(100, 5)
+	l.now = func() time.Time { return now }
+	l.last = now
+
+	for i := 0; i < 5; i++ {
+		if l.reserve(PriorityInteractive) != 0 {
+			t.Fatalf("Request %d was throttled", i)
+		}
+	}
+	if delay := l.reserve(PriorityInteractive); delay < time.Minute {
+		t.Errorf("Hourly quota not enforced, delay %v", delay)
+	}
+}
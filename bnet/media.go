@@ -0,0 +1,57 @@
+package bnet
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/salmondx/wow-twitch-extension/model"
+)
+
+// Keys of character media assets
+const (
+	MediaAvatar  = "avatar"
+	MediaInset   = "inset"
+	MediaMain    = "main"
+	MediaMainRaw = "main-raw"
+)
+
+// CharacterMedia lists render URLs of a character by asset key
+type CharacterMedia struct {
+	Assets []struct {
+		Key   string `json:"key"`
+		Value string `json:"value"`
+	} `json:"assets"`
+}
+
+// Asset returns URL of an asset, or empty string if character has no such asset
+func (m CharacterMedia) Asset(key string) string {
+	for _, asset := range m.Assets {
+		if asset.Key == key {
+			return asset.Value
+		}
+	}
+	return ""
+}
+
+const characterMediaURL = "https://%s/profile/wow/character/%s/%s/character-media?namespace=%s"
+
+// GetCharacterMedia retrieves avatar and renders of a character from Profile API. Realm is a slug
+func (c *Client) GetCharacterMedia(ctx context.Context, region model.Region, realm, name string) (*CharacterMedia, error) {
+	info, err := lookupRegion(region)
+	if err != nil {
+		return nil, err
+	}
+	resource := fmt.Sprintf(characterMediaURL, info.apiHost, url.PathEscape(realm), url.PathEscape(strings.ToLower(name)),
+		Namespace(NamespaceProfile, region, false))
+	var media CharacterMedia
+	err = c.getJSON(ctx, region, resource, &media)
+	if err == errNotFound {
+		return nil, model.CharacterNotFound{fmt.Sprintf("Character not found: %s - %s", realm, name)}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &media, nil
+}
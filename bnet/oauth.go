@@ -0,0 +1,156 @@
+package bnet
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/salmondx/wow-twitch-extension/model"
+)
+
+const tokenURL = "https://%s/token"
+
+// tokens are renewed a bit earlier than they expire, so a token doesn't expire in flight
+const tokenRenewal = time.Minute
+
+type accessToken struct {
+	value   string
+	expires time.Time
+}
+
+var (
+	errNoClientID = errors.New("Battle.Net client id is not configured")
+	errNotFound   = errors.New("Battle.Net resource not found")
+	// errUnauthorized is returned when access token is rejected
+	errUnauthorized = errors.New("Battle.Net access token is rejected")
+)
+
+// token returns client credentials access token for Game Data and Profile APIs of a region
+func (c *Client) token(ctx context.Context, oauthHost string) (string, error) {
+	if c.clientID == "" {
+		return "", errNoClientID
+	}
+	c.mu.Lock()
+	token, ok := c.tokens[oauthHost]
+	c.mu.Unlock()
+	if ok && time.Until(token.expires) > tokenRenewal {
+		return token.value, nil
+	}
+
+	grant, err := c.requestToken(ctx, oauthHost, url.Values{"grant_type": {"client_credentials"}})
+	if err != nil {
+		return "", err
+	}
+	token = accessToken{grant.AccessToken, time.Now().Add(time.Duration(grant.ExpiresIn) * time.Second)}
+	c.mu.Lock()
+	c.tokens[oauthHost] = token
+	c.mu.Unlock()
+	return token.value, nil
+}
+
+// tokenGrant is a response of OAuth token endpoint
+type tokenGrant struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// requestToken requests access token with client credentials of the application
+func (c *Client) requestToken(ctx context.Context, oauthHost string, form url.Values) (*tokenGrant, error) {
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf(tokenURL, oauthHost), strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(c.clientID, c.secret)
+	resp, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, model.UpstreamUnavailableError{S: "Failed to retrieve Battle.Net access token", Err: err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(resp)
+	}
+
+	var grant tokenGrant
+	if err := json.NewDecoder(resp.Body).Decode(&grant); err != nil {
+		return nil, fmt.Errorf("Can't deserialize access token: %v", err)
+	}
+	return &grant, nil
+}
+
+// getJSON retrieves a Game Data or Profile API resource into v. Rejected token is dropped, so the next request gets a new one
+func (c *Client) getJSON(ctx context.Context, region model.Region, resource string, v interface{}) error {
+	info, err := lookupRegion(region)
+	if err != nil {
+		return err
+	}
+	token, err := c.token(ctx, info.oauthHost)
+	if err != nil {
+		return err
+	}
+	err = c.getJSONWithToken(ctx, region, resource, token, v)
+	if err == errUnauthorized {
+		c.mu.Lock()
+		delete(c.tokens, info.oauthHost)
+		c.mu.Unlock()
+	}
+	return err
+}
+
+// getJSONWithToken retrieves a Profile API resource on behalf of the token owner
+func (c *Client) getJSONWithToken(ctx context.Context, region model.Region, resource, token string, v interface{}) error {
+	header := make(http.Header)
+	header.Set("Authorization", "Bearer "+token)
+	resp, err := c.get(ctx, region, resource, header)
+	if err != nil {
+		var unavailable model.UpstreamUnavailableError
+		if errors.As(err, &unavailable) || ctx.Err() != nil {
+			return err
+		}
+		return model.UpstreamUnavailableError{S: "Failed to retrieve " + resource, Err: err}
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return errUnauthorized
+	case http.StatusNotFound:
+		return errNotFound
+	default:
+		return statusError(resp)
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(v)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("Can't deserialize %s: %v", resource, err)
+	}
+	return nil
+}
+
+// refResource returns localized URL of a resource referenced by another one.
+// Only API host of the region is accepted, so access token never leaves Battle.Net
+func refResource(region model.Region, href, locale string) (string, error) {
+	info, err := lookupRegion(region)
+	if err != nil {
+		return "", err
+	}
+	resource, err := url.Parse(href)
+	if err != nil || resource.Host != info.apiHost {
+		return "", fmt.Errorf("Invalid resource reference %q", href)
+	}
+	query := resource.Query()
+	query.Set("locale", ResolveLocale(region, locale))
+	resource.RawQuery = query.Encode()
+	return resource.String(), nil
+}
@@ -0,0 +1,116 @@
+package bnet
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/salmondx/wow-twitch-extension/model"
+)
+
+// Bracket types of PvP bracket statistics
+const (
+	BracketArena2v2      = "ARENA_2v2"
+	BracketArena3v3      = "ARENA_3v3"
+	BracketBattlegrounds = "BATTLEGROUNDS"
+	BracketShuffle       = "SHUFFLE"
+	BracketBlitz         = "BLITZ"
+)
+
+// PvPSummary references brackets a character played in the current season. Solo Shuffle and Blitz brackets
+// are per spec, so their number varies
+type PvPSummary struct {
+	Brackets []struct {
+		Href string `json:"href"`
+	} `json:"brackets"`
+	HonorLevel int `json:"honor_level"`
+}
+
+type MatchStatistics struct {
+	Played int `json:"played"`
+	Won    int `json:"won"`
+	Lost   int `json:"lost"`
+}
+
+// PvPBracket is a rating of a character in a bracket. Slug is the last part of bracket URL, like shuffle-warrior-arms
+type PvPBracket struct {
+	Slug    string `json:"-"`
+	Bracket struct {
+		ID   int    `json:"id"`
+		Type string `json:"type"`
+	} `json:"bracket"`
+	Rating                int             `json:"rating"`
+	Season                Ref             `json:"season"`
+	Tier                  Ref             `json:"tier"`
+	Specialization        Ref             `json:"specialization"`
+	SeasonMatchStatistics MatchStatistics `json:"season_match_statistics"`
+	WeeklyMatchStatistics MatchStatistics `json:"weekly_match_statistics"`
+}
+
+// PvPTier is a rank rewarded for rating, like Combatant or Elite
+type PvPTier struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	MinRating int    `json:"min_rating"`
+	MaxRating int    `json:"max_rating"`
+}
+
+const pvpSummaryURL = "https://%s/profile/wow/character/%s/%s/pvp-summary?namespace=%s&locale=%s"
+
+// GetPvPSummary retrieves PvP brackets of a character from Profile API. Realm is a slug
+func (c *Client) GetPvPSummary(ctx context.Context, region model.Region, realm, name, locale string) (*PvPSummary, error) {
+	info, err := lookupRegion(region)
+	if err != nil {
+		return nil, err
+	}
+	resource := fmt.Sprintf(pvpSummaryURL, info.apiHost, url.PathEscape(realm), url.PathEscape(strings.ToLower(name)),
+		Namespace(NamespaceProfile, region, false), ResolveLocale(region, locale))
+	var summary PvPSummary
+	err = c.getJSON(ctx, region, resource, &summary)
+	if err == errNotFound {
+		return nil, model.CharacterNotFound{fmt.Sprintf("Character not found: %s - %s", realm, name)}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+// GetPvPBracket retrieves statistics of a bracket referenced by PvP summary
+func (c *Client) GetPvPBracket(ctx context.Context, region model.Region, href, locale string) (*PvPBracket, error) {
+	resource, err := refResource(region, href, locale)
+	if err != nil {
+		return nil, err
+	}
+	var bracket PvPBracket
+	err = c.getJSON(ctx, region, resource, &bracket)
+	if err == errNotFound {
+		return nil, fmt.Errorf("PvP bracket %s not found", href)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if parsed, err := url.Parse(href); err == nil {
+		bracket.Slug = path.Base(parsed.Path)
+	}
+	return &bracket, nil
+}
+
+// GetPvPTier retrieves a tier referenced by a bracket
+func (c *Client) GetPvPTier(ctx context.Context, region model.Region, tier Ref, locale string) (*PvPTier, error) {
+	resource, err := refResource(region, tier.Key.Href, locale)
+	if err != nil {
+		return nil, err
+	}
+	var pvpTier PvPTier
+	err = c.getJSON(ctx, region, resource, &pvpTier)
+	if err == errNotFound {
+		return nil, fmt.Errorf("PvP tier %s not found", tier.Key.Href)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &pvpTier, nil
+}
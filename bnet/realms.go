@@ -0,0 +1,51 @@
+package bnet
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/salmondx/wow-twitch-extension/model"
+)
+
+// Realm is a realm display name with its slug, used in API paths
+type Realm struct {
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+const realmIndexURL = "https://%s/wow/realm/status?locale=%s&apikey=%s"
+
+// GetRealms retrieves all realms of a region
+func (c *Client) GetRealms(ctx context.Context, region model.Region) ([]Realm, error) {
+	info, err := lookupRegion(region)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.get(ctx, region, fmt.Sprintf(realmIndexURL, info.host, info.locale, c.secret), nil)
+	if err != nil {
+		var unavailable model.UpstreamUnavailableError
+		if errors.As(err, &unavailable) || ctx.Err() != nil {
+			return nil, err
+		}
+		return nil, model.UpstreamUnavailableError{S: fmt.Sprintf("Failed to retrieve realms of %s", region), Err: err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(resp)
+	}
+
+	var index struct {
+		Realms []Realm `json:"realms"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&index)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("Can't deserialize realms of %s: %v", region, err)
+	}
+	return index.Realms, nil
+}
@@ -0,0 +1,85 @@
+package bnet
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/salmondx/wow-twitch-extension/model"
+)
+
+// regionInfo describes Battle.Net API of a region
+type regionInfo struct {
+	// host serves community API
+	host string
+	// locale is used when request doesn't specify one
+	locale string
+	// locales are all locales the region serves
+	locales []string
+	// apiHost serves Game Data and Profile APIs, authorized with tokens from oauthHost
+	apiHost   string
+	oauthHost string
+}
+
+var regions = map[model.Region]regionInfo{
+	model.US: {"us.api.battle.net", "en_US", []string{"en_US", "es_MX", "pt_BR"}, "us.api.blizzard.com", "oauth.battle.net"},
+	model.EU: {"eu.api.battle.net", "en_GB", []string{"en_GB", "de_DE", "es_ES", "fr_FR", "it_IT", "pt_PT", "ru_RU"}, "eu.api.blizzard.com", "oauth.battle.net"},
+	model.KR: {"kr.api.battle.net", "ko_KR", []string{"ko_KR"}, "kr.api.blizzard.com", "oauth.battle.net"},
+	model.TW: {"tw.api.battle.net", "zh_TW", []string{"zh_TW"}, "tw.api.blizzard.com", "oauth.battle.net"},
+	// China is served by a separate gateway
+	model.CN: {"api.battlenet.com.cn", "zh_CN", []string{"zh_CN"}, "gateway.battlenet.com.cn", "oauth.battlenet.com.cn"},
+}
+
+// NamespaceKind is a kind of data in Battle.Net Game Data and Profile APIs
+type NamespaceKind string
+
+const (
+	NamespaceStatic  NamespaceKind = "static"
+	NamespaceDynamic NamespaceKind = "dynamic"
+	NamespaceProfile NamespaceKind = "profile"
+)
+
+// Namespace returns namespace of a region for retail or Classic game data
+func Namespace(kind NamespaceKind, region model.Region, classic bool) string {
+	if classic {
+		return fmt.Sprintf("%s-classic-%s", kind, region)
+	}
+	return fmt.Sprintf("%s-%s", kind, region)
+}
+
+func lookupRegion(region model.Region) (regionInfo, error) {
+	info, ok := regions[region]
+	if !ok {
+		return regionInfo{}, model.InvalidRegionError{fmt.Sprintf("Region %q is not supported", region)}
+	}
+	return info, nil
+}
+
+// Locales returns locales served in region, the default one first
+func Locales(region model.Region) []string {
+	return regions[region].locales
+}
+
+// ResolveLocale picks the first of preferred locales served in region, falling back to region default.
+// Preferences may be locales like ru_RU or ru-RU, or just languages like ru.
+// Locale of another country matches by language, so pt_PT is served as pt_BR in US
+func ResolveLocale(region model.Region, preferred ...string) string {
+	info, ok := regions[region]
+	if !ok {
+		return ""
+	}
+	for _, preference := range preferred {
+		preference = strings.Replace(strings.TrimSpace(preference), "-", "_", -1)
+		for _, locale := range info.locales {
+			if strings.EqualFold(locale, preference) {
+				return locale
+			}
+		}
+		language := strings.SplitN(preference, "_", 2)[0]
+		for _, locale := range info.locales {
+			if strings.EqualFold(locale[:2], language) {
+				return locale
+			}
+		}
+	}
+	return info.locale
+}
@@ -0,0 +1,57 @@
+package bnet
+
+import (
+	"testing"
+
+	"github.com/salmondx/wow-twitch-extension/model"
+)
+
+func TestRegions(t *testing.T) {
+	for _, region := range model.Regions {
+		info, err := lookupRegion(region)
+		if err != nil {
+			t.Errorf("Region %s is not described", region)
+			continue
+		}
+		if info.locales[0] != info.locale {
+			t.Errorf("Default locale of %s is not the first one", region)
+		}
+	}
+	if info, _ := lookupRegion(model.TW); info.locale != "zh_TW" {
+		t.Errorf("Wrong Taiwan locale %s", info.locale)
+	}
+	if info, _ := lookupRegion(model.CN); info.host != "api.battlenet.com.cn" {
+		t.Errorf("Wrong China host %s", info.host)
+	}
+	if _, err := lookupRegion("sea"); err == nil {
+		t.Errorf("Unknown region accepted")
+	}
+}
+
+func TestNamespace(t *testing.T) {
+	if ns := Namespace(NamespaceProfile, model.EU, false); ns != "profile-eu" {
+		t.Errorf("Wrong namespace %s", ns)
+	}
+	if ns := Namespace(NamespaceStatic, model.US, true); ns != "static-classic-us" {
+		t.Errorf("Wrong Classic namespace %s", ns)
+	}
+}
+
+func TestResolveLocale(t *testing.T) {
+	var tests = []struct {
+		region    model.Region
+		preferred []string
+		locale    string
+	}{
+		{model.EU, nil, "en_GB"},
+		{model.EU, []string{"de-DE"}, "de_DE"},
+		{model.EU, []string{"ko-KR", "fr"}, "fr_FR"},
+		{model.US, []string{"pt-PT"}, "pt_BR"},
+		{model.KR, []string{"ru_RU"}, "ko_KR"},
+	}
+	for _, tt := range tests {
+		if locale := ResolveLocale(tt.region, tt.preferred...); locale != tt.locale {
+			t.Errorf("%s %v: %s, expected %s", tt.region, tt.preferred, locale, tt.locale)
+		}
+	}
+}
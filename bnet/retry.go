@@ -0,0 +1,60 @@
+package bnet
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	maxAttempts = 3
+	baseBackoff = 200 * time.Millisecond
+	maxBackoff  = 2 * time.Second
+)
+
+// retryable reports whether a response status is worth another attempt
+func retryable(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// backoff returns a full jitter delay for the given attempt, starting with 0.
+// Retry-After header of the previous response takes precedence if present
+func backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if delay, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			return delay
+		}
+	}
+	ceiling := baseBackoff << uint(attempt)
+	if ceiling > maxBackoff {
+		ceiling = maxBackoff
+	}
+	return time.Duration(rand.Int63n(int64(ceiling)))
+}
+
+// retryAfter parses Retry-After value that is either seconds or an HTTP date
+func retryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		delay := time.Until(date)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return 0, false
+}
@@ -0,0 +1,130 @@
+package bnet
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/salmondx/wow-twitch-extension/model"
+)
+
+// Ref is a reference to another Game Data resource
+type Ref struct {
+	Key struct {
+		Href string `json:"href"`
+	} `json:"key"`
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// TypeRef is an enumerated value with its localized name, like {"type": "HORDE", "name": "Horde"}
+type TypeRef struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+}
+
+type SpellTooltip struct {
+	Spell       Ref    `json:"spell"`
+	Description string `json:"description"`
+}
+
+type TalentTooltip struct {
+	Talent       Ref          `json:"talent"`
+	SpellTooltip SpellTooltip `json:"spell_tooltip"`
+}
+
+// SelectedTalent is a talent tree node with points spent. ID is the node id
+type SelectedTalent struct {
+	ID      int           `json:"id"`
+	Rank    int           `json:"rank"`
+	Tooltip TalentTooltip `json:"tooltip"`
+}
+
+// Loadout is a saved talent build. Code is the in-game export string
+type Loadout struct {
+	IsActive                bool             `json:"is_active"`
+	TalentLoadoutCode       string           `json:"talent_loadout_code"`
+	SelectedClassTalents    []SelectedTalent `json:"selected_class_talents"`
+	SelectedSpecTalents     []SelectedTalent `json:"selected_spec_talents"`
+	SelectedHeroTalents     []SelectedTalent `json:"selected_hero_talents"`
+	SelectedClassTalentTree Ref              `json:"selected_class_talent_tree"`
+	SelectedSpecTalentTree  Ref              `json:"selected_spec_talent_tree"`
+	SelectedHeroTalentTree  Ref              `json:"selected_hero_talent_tree"`
+}
+
+type SpecializationLoadouts struct {
+	Specialization Ref       `json:"specialization"`
+	Loadouts       []Loadout `json:"loadouts"`
+}
+
+// Specializations are talent loadouts of every spec of a character
+type Specializations struct {
+	ActiveSpecialization Ref                      `json:"active_specialization"`
+	Specializations      []SpecializationLoadouts `json:"specializations"`
+}
+
+// TalentTreeNode is a node of a talent tree. Every rank is a point to spend, choice nodes offer several talents
+type TalentTreeNode struct {
+	ID       int `json:"id"`
+	NodeType struct {
+		Type string `json:"type"`
+	} `json:"node_type"`
+	Ranks []struct {
+		Rank int `json:"rank"`
+	} `json:"ranks"`
+}
+
+type HeroTalentTree struct {
+	ID              int              `json:"id"`
+	Name            string           `json:"name"`
+	HeroTalentNodes []TalentTreeNode `json:"hero_talent_nodes"`
+}
+
+// TalentTree is a talent tree of a spec with its class tree and hero trees
+type TalentTree struct {
+	ID               int              `json:"id"`
+	Name             string           `json:"name"`
+	ClassTalentNodes []TalentTreeNode `json:"class_talent_nodes"`
+	SpecTalentNodes  []TalentTreeNode `json:"spec_talent_nodes"`
+	HeroTalentTrees  []HeroTalentTree `json:"hero_talent_trees"`
+}
+
+const specializationsURL = "https://%s/profile/wow/character/%s/%s/specializations?namespace=%s&locale=%s"
+
+// GetSpecializations retrieves talent loadouts of a character from Profile API. Realm is a slug.
+// Names are localized with locale, empty locale stands for region default
+func (c *Client) GetSpecializations(ctx context.Context, region model.Region, realm, name, locale string) (*Specializations, error) {
+	info, err := lookupRegion(region)
+	if err != nil {
+		return nil, err
+	}
+	resource := fmt.Sprintf(specializationsURL, info.apiHost, url.PathEscape(realm), url.PathEscape(strings.ToLower(name)),
+		Namespace(NamespaceProfile, region, false), ResolveLocale(region, locale))
+	var specializations Specializations
+	err = c.getJSON(ctx, region, resource, &specializations)
+	if err == errNotFound {
+		return nil, model.CharacterNotFound{fmt.Sprintf("Character not found: %s - %s", realm, name)}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &specializations, nil
+}
+
+// GetTalentTree retrieves a talent tree by reference from a loadout
+func (c *Client) GetTalentTree(ctx context.Context, region model.Region, tree Ref, locale string) (*TalentTree, error) {
+	resource, err := refResource(region, tree.Key.Href, locale)
+	if err != nil {
+		return nil, err
+	}
+	var talentTree TalentTree
+	err = c.getJSON(ctx, region, resource, &talentTree)
+	if err == errNotFound {
+		return nil, fmt.Errorf("Talent tree %s not found", tree.Key.Href)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &talentTree, nil
+}
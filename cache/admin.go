@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/salmondx/wow-twitch-extension/model"
+)
+
+// EvictProfiles removes cached profiles of a character in all locales. Realm is a slug.
+// Returns number of removed profiles
+func (cache *CacheClient) EvictProfiles(ctx context.Context, streamerID string, region model.Region, realm, name string) (int, error) {
+	return cache.deleteMatching(ctx, escapePattern(createProfileKey(streamerID, region, realm, name, ""))+"*")
+}
+
+// EvictChannel removes cached list and all cached profiles of a streamer. Returns number of removed profiles
+func (cache *CacheClient) EvictChannel(ctx context.Context, streamerID string) (int, error) {
+	if streamerID == "" {
+		return 0, fmt.Errorf("StreamerID can not be empty")
+	}
+	if err := cache.ClearList(ctx, streamerID); err != nil {
+		return 0, err
+	}
+	return cache.deleteMatching(ctx, escapePattern(streamerID)+":*")
+}
+
+// deleteMatching removes keys matching a glob pattern. SCAN is used, so Redis is not blocked on large databases
+func (cache *CacheClient) deleteMatching(ctx context.Context, pattern string) (int, error) {
+	conn, err := cache.pool.GetContext(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("Can't get redis connection for %s. Reason: %v", pattern, err)
+	}
+	defer conn.Close()
+
+	removed := 0
+	cursor := 0
+	for {
+		values, err := redis.Values(conn.Do("SCAN", cursor, "MATCH", pattern, "COUNT", 100))
+		if err != nil {
+			return removed, fmt.Errorf("Can't scan %s. Reason: %v", pattern, err)
+		}
+		var keys []interface{}
+		if _, err := redis.Scan(values, &cursor, &keys); err != nil {
+			return removed, fmt.Errorf("Can't scan %s. Reason: %v", pattern, err)
+		}
+		if len(keys) > 0 {
+			deleted, err := redis.Int(conn.Do("DEL", keys...))
+			if err != nil {
+				return removed, fmt.Errorf("Can't delete keys of %s. Reason: %v", pattern, err)
+			}
+			removed += deleted
+		}
+		if cursor == 0 {
+			return removed, nil
+		}
+	}
+}
+
+// escapePattern escapes glob characters of a key, so names are matched literally
+func escapePattern(key string) string {
+	var b strings.Builder
+	for _, r := range key {
+		if strings.ContainsRune(`*?[]\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
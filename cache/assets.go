@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// Assets share Redis with profiles, so their total size is limited. Least recently used assets are evicted
+// down to 90% of the limit, like in the disk store
+const assetLimit = 64 << 20
+
+const (
+	assetKeyPrefix = "asset:"
+	// assetUsageKey is a sorted set of asset keys by last use, assetSizesKey is a hash of their sizes
+	// and assetTotalKey is their total size
+	assetUsageKey = "assets:used"
+	assetSizesKey = "assets:sizes"
+	assetTotalKey = "assets:size"
+)
+
+// putAssetScript saves an asset and evicts least recently used ones atomically, so concurrent writers
+// never count an asset twice
+var putAssetScript = redis.NewScript(4, `
+local size = string.len(ARGV[2])
+local previous = tonumber(redis.call('HGET', KEYS[3], ARGV[1]) or '0')
+redis.call('SET', KEYS[1], ARGV[2])
+redis.call('ZADD', KEYS[2], ARGV[3], ARGV[1])
+redis.call('HSET', KEYS[3], ARGV[1], size)
+local total = redis.call('INCRBY', KEYS[4], size - previous)
+if total <= tonumber(ARGV[4]) then
+	return 0
+end
+local evicted = 0
+while total > tonumber(ARGV[5]) do
+	local oldest = redis.call('ZRANGE', KEYS[2], 0, 0)
+	if #oldest == 0 then
+		break
+	end
+	local oldestSize = tonumber(redis.call('HGET', KEYS[3], oldest[1]) or '0')
+	redis.call('DEL', ARGV[6] .. oldest[1])
+	redis.call('ZREM', KEYS[2], oldest[1])
+	redis.call('HDEL', KEYS[3], oldest[1])
+	total = redis.call('DECRBY', KEYS[4], oldestSize)
+	evicted = evicted + 1
+end
+return evicted
+`)
+
+func createAssetKey(key string) string {
+	return assetKeyPrefix + key
+}
+
+// GetAsset returns proxied image, or nil if it's not cached
+func (cache *CacheClient) GetAsset(ctx context.Context, key string) ([]byte, error) {
+	conn, err := cache.pool.GetContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Can't get redis connection for asset %s. Reason: %v", key, err)
+	}
+	defer conn.Close()
+
+	data, err := redis.Bytes(conn.Do("GET", createAssetKey(key)))
+	if err == redis.ErrNil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Can't get asset %s. Reason: %v", key, err)
+	}
+	// recent use keeps popular assets from eviction
+	_, err = conn.Do("ZADD", assetUsageKey, "XX", time.Now().UnixMilli(), key)
+	if err != nil {
+		log.Printf("[WARN] Can't mark use of asset %s. %v", key, err)
+	}
+	return data, nil
+}
+
+// PutAsset caches proxied image, evicting least recently used images when total size exceeds the limit
+func (cache *CacheClient) PutAsset(ctx context.Context, key string, data []byte) error {
+	conn, err := cache.pool.GetContext(ctx)
+	if err != nil {
+		return fmt.Errorf("Can't get redis connection for asset %s. Reason: %v", key, err)
+	}
+	defer conn.Close()
+
+	evicted, err := redis.Int(putAssetScript.Do(conn, createAssetKey(key), assetUsageKey, assetSizesKey, assetTotalKey,
+		key, data, time.Now().UnixMilli(), assetLimit, assetLimit/10*9, assetKeyPrefix))
+	if err != nil {
+		return fmt.Errorf("Can't save asset %s. Reason: %v", key, err)
+	}
+	if evicted > 0 {
+		log.Printf("[INFO] Evicted %d least recently used assets", evicted)
+	}
+	return nil
+}
@@ -1,17 +1,43 @@
 package cache
 
-import "github.com/salmondx/wow-twitch-extension/model"
+import (
+	"context"
+
+	"github.com/salmondx/wow-twitch-extension/bnet"
+	"github.com/salmondx/wow-twitch-extension/model"
+)
 
 // Cache is an interface for caching characters list and full character profiles
 type Cache interface {
-	List(streamerID string) ([]*model.CharacterInfo, error)
-	AddCharacters(streamerID string, characterInfos []*model.CharacterInfo) error
-	GetProfile(streamerID, region, realm, name string) (*model.Character, error)
-	AddProfile(streamerID string, character *model.Character) error
-	Update(streamerID string, character *model.Character) error
-	ClearList(streamerID string) error
+	List(ctx context.Context, streamerID string) ([]*model.CharacterInfo, error)
+	AddCharacters(ctx context.Context, streamerID string, characterInfos []*model.CharacterInfo) error
+	// GetProfile returns profile in given locale only if it is still fresh
+	GetProfile(ctx context.Context, streamerID string, region model.Region, realm, name, locale string) (*model.Character, error)
+	// GetStaleProfile returns last saved profile even if it's expired, together with Battle.Net validators to revalidate it
+	GetStaleProfile(ctx context.Context, streamerID string, region model.Region, realm, name, locale string) (*model.Character, bnet.Validators, error)
+	// AddProfile saves profile under its locale
+	AddProfile(ctx context.Context, streamerID string, character *model.Character, validators bnet.Validators) error
+	// AddPartialProfile saves profile that misses some details for a short time and without validators,
+	// so it's never revalidated and is downloaded again
+	AddPartialProfile(ctx context.Context, streamerID string, character *model.Character) error
+	// Touch makes saved profile fresh again, when Battle.Net reports it's not modified
+	Touch(ctx context.Context, streamerID string, region model.Region, realm, name, locale string) error
+	Update(ctx context.Context, streamerID string, character *model.Character, validators bnet.Validators) error
+	ClearList(ctx context.Context, streamerID string) error
+	// GetGuild returns guild profile in given locale. Guilds are shared by all channels
+	GetGuild(ctx context.Context, region model.Region, realm, name, locale string) (*model.Guild, error)
+	AddGuild(ctx context.Context, guild *model.Guild) error
+	// GetImport returns unfinished account import by its state
+	GetImport(ctx context.Context, state string) (*model.AccountImport, error)
+	SaveImport(ctx context.Context, accountImport *model.AccountImport) error
+}
+
+// createProfileKey expects realm slug. Profiles are localized, so every locale has its own key
+func createProfileKey(streamerID string, region model.Region, realm, name, locale string) string {
+	return streamerID + ":" + model.CharacterID(region, realm, name) + ":" + locale
 }
 
-func createProfileKey(streamerID, region, realm, name string) string {
-	return streamerID + ":" + region + ":" + realm + ":" + name
+// createGuildKey expects realm slug
+func createGuildKey(region model.Region, realm, name, locale string) string {
+	return "guild:" + model.CharacterID(region, realm, model.Slug(name)) + ":" + locale
 }
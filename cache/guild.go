@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/salmondx/wow-twitch-extension/model"
+)
+
+// Rosters change more often than profiles, 6 hours
+const guildTimeout = 6 * 60 * 60
+
+func (cache *CacheClient) GetGuild(ctx context.Context, region model.Region, realm, name, locale string) (*model.Guild, error) {
+	if realm == "" || name == "" {
+		return nil, errors.New("Realm or guild name can not be empty")
+	}
+	conn, err := cache.pool.GetContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Can't get redis connection for guild %s. Reason: %v", name, err)
+	}
+	defer conn.Close()
+
+	data, err := redis.Bytes(conn.Do("GET", createGuildKey(region, realm, name, locale)))
+	if err != nil {
+		return nil, fmt.Errorf("Can't get guild %s - %s. Reason: %v", realm, name, err)
+	}
+	var guild model.Guild
+	err = json.Unmarshal(data, &guild)
+	if err != nil {
+		return nil, fmt.Errorf("Can't deserialize guild %s - %s. Reason: %v", realm, name, err)
+	}
+	return &guild, nil
+}
+
+func (cache *CacheClient) AddGuild(ctx context.Context, guild *model.Guild) error {
+	if guild == nil {
+		return errors.New("Guild can not be null")
+	}
+	data, err := json.Marshal(guild)
+	if err != nil {
+		return fmt.Errorf("Can't serialize guild %s. Reason: %v", guild.Name, err)
+	}
+	conn, err := cache.pool.GetContext(ctx)
+	if err != nil {
+		return fmt.Errorf("Can't get redis connection for guild %s. Reason: %v", guild.Name, err)
+	}
+	defer conn.Close()
+
+	_, err = conn.Do("SET", createGuildKey(guild.Region, guild.RealmSlug, guild.Name, guild.Locale), data, "EX", guildTimeout)
+	if err != nil {
+		return fmt.Errorf("Can't save guild %s. Reason: %v", guild.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/salmondx/wow-twitch-extension/model"
+)
+
+// Broadcaster has 30 minutes to authorize and pick characters
+const importTimeout = 30 * 60
+
+func createImportKey(state string) string {
+	return "import:" + state
+}
+
+func (cache *CacheClient) GetImport(ctx context.Context, state string) (*model.AccountImport, error) {
+	if state == "" {
+		return nil, errors.New("Import state can not be empty")
+	}
+	conn, err := cache.pool.GetContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Can't get redis connection for import %s. Reason: %v", state, err)
+	}
+	defer conn.Close()
+
+	data, err := redis.Bytes(conn.Do("GET", createImportKey(state)))
+	if err != nil {
+		return nil, fmt.Errorf("Can't get import %s. Reason: %v", state, err)
+	}
+	var accountImport model.AccountImport
+	err = json.Unmarshal(data, &accountImport)
+	if err != nil {
+		return nil, fmt.Errorf("Can't deserialize import %s. Reason: %v", state, err)
+	}
+	return &accountImport, nil
+}
+
+// SaveImport saves import. Expiration is not extended, so an import can't be kept alive
+func (cache *CacheClient) SaveImport(ctx context.Context, accountImport *model.AccountImport) error {
+	if accountImport == nil || accountImport.State == "" {
+		return errors.New("Import or its state can not be empty")
+	}
+	data, err := json.Marshal(accountImport)
+	if err != nil {
+		return fmt.Errorf("Can't serialize import %s. Reason: %v", accountImport.State, err)
+	}
+	conn, err := cache.pool.GetContext(ctx)
+	if err != nil {
+		return fmt.Errorf("Can't get redis connection for import %s. Reason: %v", accountImport.State, err)
+	}
+	defer conn.Close()
+
+	key := createImportKey(accountImport.State)
+	// KEEPTTL requires Redis 6, so remaining time is read first
+	ttl, err := redis.Int(conn.Do("TTL", key))
+	if err != nil || ttl <= 0 {
+		ttl = importTimeout
+	}
+	_, err = conn.Do("SET", key, data, "EX", ttl)
+	if err != nil {
+		return fmt.Errorf("Can't save import %s. Reason: %v", accountImport.State, err)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/salmondx/wow-twitch-extension/bnet"
+)
+
+const limiterKeyPrefix = "bnet:quota:"
+
+// RedisLimiter is a bnet.Limiter sharing Battle.Net quotas between all instances.
+// It counts requests in fixed per second and per hour windows.
+// Local limiter is always applied first and is the only one used while Redis is unavailable
+type RedisLimiter struct {
+	pool      *redis.Pool
+	local     bnet.Limiter
+	perSecond int
+	perHour   int
+}
+
+// NewLimiter creates a limiter that stores quota counters in the same Redis as the cache
+func NewLimiter(cache *CacheClient, local bnet.Limiter, perSecond, perHour int) *RedisLimiter {
+	return &RedisLimiter{
+		pool:      cache.pool,
+		local:     local,
+		perSecond: perSecond,
+		perHour:   perHour,
+	}
+}
+
+func (l *RedisLimiter) Wait(ctx context.Context, priority bnet.Priority) error {
+	err := l.local.Wait(ctx, priority)
+	if err != nil {
+		return err
+	}
+	for {
+		delay, err := l.reserve(ctx, priority)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Printf("[WARN] Can't check shared Battle.Net quota, using local limit only. %v", err)
+			return nil
+		}
+		if delay == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// reserve counts request in current windows or returns time left until the exceeded window ends
+func (l *RedisLimiter) reserve(ctx context.Context, priority bnet.Priority) (time.Duration, error) {
+	conn, err := l.pool.GetContext(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("Can't get redis connection. Reason: %v", err)
+	}
+	defer conn.Close()
+
+	now := time.Now()
+	second := now.Truncate(time.Second)
+	hour := now.Truncate(time.Hour)
+	secondKey := fmt.Sprintf("%ss:%d", limiterKeyPrefix, second.Unix())
+	hourKey := fmt.Sprintf("%sh:%d", limiterKeyPrefix, hour.Unix())
+
+	conn.Send("MULTI")
+	conn.Send("INCR", secondKey)
+	conn.Send("EXPIRE", secondKey, 2)
+	conn.Send("INCR", hourKey)
+	conn.Send("EXPIRE", hourKey, 2*60*60)
+	counters, err := redis.Ints(conn.Do("EXEC"))
+	if err != nil {
+		return 0, fmt.Errorf("Can't increment quota counters. Reason: %v", err)
+	}
+
+	secondCount, hourCount := counters[0], counters[2]
+	if secondCount <= limit(l.perSecond, priority) && hourCount <= limit(l.perHour, priority) {
+		return 0, nil
+	}
+
+	// rejected request must not take quota from others
+	conn.Send("MULTI")
+	conn.Send("DECR", secondKey)
+	conn.Send("DECR", hourKey)
+	if _, err = conn.Do("EXEC"); err != nil {
+		log.Printf("[WARN] Can't release quota counters. %v", err)
+	}
+	if hourCount > limit(l.perHour, priority) {
+		return hour.Add(time.Hour).Sub(now), nil
+	}
+	return second.Add(time.Second).Sub(now), nil
+}
+
+// limit leaves part of the quota to interactive requests
+func limit(quota int, priority bnet.Priority) int {
+	if priority == bnet.PriorityBackground {
+		return quota * 4 / 5
+	}
+	return quota
+}
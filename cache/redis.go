@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -8,6 +9,7 @@ import (
 	"time"
 
 	"github.com/garyburd/redigo/redis"
+	"github.com/salmondx/wow-twitch-extension/bnet"
 	"github.com/salmondx/wow-twitch-extension/model"
 )
 
@@ -30,11 +32,32 @@ func New(address string) *CacheClient {
 // 24 hours
 const expirationTimeout = 24 * 60 * 60
 
-func (cache *CacheClient) List(streamerID string) ([]*model.CharacterInfo, error) {
+// Partial profiles are fresh for 10 minutes, so missing details are retried soon
+const partialTimeout = 10 * 60
+
+// Profiles are kept for 7 days after they expire, so they can be revalidated instead of downloaded again
+const staleTimeout = 7 * 24 * 60 * 60
+
+// cachedProfile is a profile saved with its Battle.Net validators.
+// Profiles saved without Expires are fresh until the key expires
+type cachedProfile struct {
+	*model.Character
+	Validators bnet.Validators `json:",omitempty"`
+	Expires    int64           `json:",omitempty"`
+}
+
+func (p *cachedProfile) fresh() bool {
+	return p.Expires == 0 || time.Now().Unix() < p.Expires
+}
+
+func (cache *CacheClient) List(ctx context.Context, streamerID string) ([]*model.CharacterInfo, error) {
 	if streamerID == "" {
 		return nil, errors.New("StreamerID can not be empty")
 	}
-	conn := cache.pool.Get()
+	conn, err := cache.pool.GetContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Can't get redis connection for %s. Reason: %v", streamerID, err)
+	}
 	defer conn.Close()
 
 	data, err := redis.Bytes(conn.Do("GET", streamerID))
@@ -49,11 +72,14 @@ func (cache *CacheClient) List(streamerID string) ([]*model.CharacterInfo, error
 	return characters, nil
 }
 
-func (cache *CacheClient) AddCharacters(streamerID string, characterInfos []*model.CharacterInfo) error {
+func (cache *CacheClient) AddCharacters(ctx context.Context, streamerID string, characterInfos []*model.CharacterInfo) error {
 	if streamerID == "" {
 		return errors.New("StreamerID can not be empty")
 	}
-	conn := cache.pool.Get()
+	conn, err := cache.pool.GetContext(ctx)
+	if err != nil {
+		return fmt.Errorf("Can't get redis connection for %s. Reason: %v", streamerID, err)
+	}
 	defer conn.Close()
 
 	bytes, err := json.Marshal(characterInfos)
@@ -70,44 +96,90 @@ func (cache *CacheClient) AddCharacters(streamerID string, characterInfos []*mod
 	return nil
 }
 
-func (cache *CacheClient) GetProfile(streamerID, region, realm, name string) (*model.Character, error) {
+func (cache *CacheClient) GetProfile(ctx context.Context, streamerID string, region model.Region, realm, name, locale string) (*model.Character, error) {
+	profile, err := cache.getProfile(ctx, streamerID, region, realm, name, locale)
+	if err != nil {
+		return nil, err
+	}
+	if !profile.fresh() {
+		return nil, fmt.Errorf("Profile for %s expired", streamerID)
+	}
+	return profile.Character, nil
+}
+
+func (cache *CacheClient) GetStaleProfile(ctx context.Context, streamerID string, region model.Region, realm, name, locale string) (*model.Character, bnet.Validators, error) {
+	profile, err := cache.getProfile(ctx, streamerID, region, realm, name, locale)
+	if err != nil {
+		return nil, bnet.Validators{}, err
+	}
+	return profile.Character, profile.Validators, nil
+}
+
+func (cache *CacheClient) getProfile(ctx context.Context, streamerID string, region model.Region, realm, name, locale string) (*cachedProfile, error) {
 	if streamerID == "" || realm == "" || name == "" {
 		return nil, errors.New("StreamerID, realm or name can not be empty")
 	}
 
-	conn := cache.pool.Get()
+	conn, err := cache.pool.GetContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Can't get redis connection for %s. Reason: %v", streamerID, err)
+	}
 	defer conn.Close()
 
-	key := createProfileKey(streamerID, region, realm, name)
+	key := createProfileKey(streamerID, region, realm, name, locale)
 	bytes, err := redis.Bytes(conn.Do("GET", key))
 	if err != nil {
 		return nil, fmt.Errorf("Can't get profile for %s. Reason: %v", streamerID, err)
 	}
 
-	var character model.Character
-	err = json.Unmarshal(bytes, &character)
+	profile := cachedProfile{Character: &model.Character{}}
+	err = json.Unmarshal(bytes, &profile)
 	if err != nil {
 		return nil, fmt.Errorf("Can't serialize profile for %s. Reason: %v", streamerID, err)
 	}
-	return &character, nil
+	return &profile, nil
 }
 
-func (cache *CacheClient) AddProfile(streamerID string, character *model.Character) error {
+func (cache *CacheClient) AddProfile(ctx context.Context, streamerID string, character *model.Character, validators bnet.Validators) error {
 	if streamerID == "" || character == nil {
 		return errors.New("StreamerID or character can not be null or empty")
 	}
+	key := createProfileKey(streamerID, character.Region, character.RealmSlug, character.Name, character.Locale)
+	return cache.saveProfile(ctx, streamerID, key, &cachedProfile{Character: character, Validators: validators}, expirationTimeout)
+}
+
+func (cache *CacheClient) AddPartialProfile(ctx context.Context, streamerID string, character *model.Character) error {
+	if streamerID == "" || character == nil {
+		return errors.New("StreamerID or character can not be null or empty")
+	}
+	key := createProfileKey(streamerID, character.Region, character.RealmSlug, character.Name, character.Locale)
+	return cache.saveProfile(ctx, streamerID, key, &cachedProfile{Character: character}, partialTimeout)
+}
+
+func (cache *CacheClient) Touch(ctx context.Context, streamerID string, region model.Region, realm, name, locale string) error {
+	profile, err := cache.getProfile(ctx, streamerID, region, realm, name, locale)
+	if err != nil {
+		return err
+	}
+	return cache.saveProfile(ctx, streamerID, createProfileKey(streamerID, region, realm, name, locale), profile, expirationTimeout)
+}
 
-	conn := cache.pool.Get()
+// saveProfile keeps profile fresh for given number of seconds
+func (cache *CacheClient) saveProfile(ctx context.Context, streamerID, key string, profile *cachedProfile, freshness int64) error {
+	conn, err := cache.pool.GetContext(ctx)
+	if err != nil {
+		return fmt.Errorf("Can't get redis connection for %s. Reason: %v", streamerID, err)
+	}
 	defer conn.Close()
 
-	key := createProfileKey(streamerID, character.Region, character.Realm, character.Name)
-	data, err := json.Marshal(character)
+	profile.Expires = time.Now().Unix() + freshness
+	data, err := json.Marshal(profile)
 	if err != nil {
 		return fmt.Errorf("Can't serialize profile for %s. Reason: %v", streamerID, err)
 	}
 	conn.Send("MULTI")
 	conn.Send("SET", key, data)
-	conn.Send("EXPIRE", key, expirationTimeout)
+	conn.Send("EXPIRE", key, freshness+staleTimeout)
 	_, err = conn.Do("EXEC")
 	if err != nil {
 		return fmt.Errorf("Can't save profile for %s. Reason: %v", streamerID, err)
@@ -115,42 +187,36 @@ func (cache *CacheClient) AddProfile(streamerID string, character *model.Charact
 	return nil
 }
 
-func (cache *CacheClient) Update(streamerID string, character *model.Character) error {
+func (cache *CacheClient) Update(ctx context.Context, streamerID string, character *model.Character, validators bnet.Validators) error {
 	if streamerID == "" || character == nil {
 		return errors.New("StreamerID or character can not be null or empty")
 	}
-	characters, err := cache.List(streamerID)
+	characters, err := cache.List(ctx, streamerID)
 	if err == nil {
-		charInfo := model.CharacterInfo{
-			CharIcon: character.CharIcon,
-			Class:    character.Class,
-			Name:     character.Name,
-			Realm:    character.Realm,
-			Region:   character.Region,
-			Guild:    character.Guild,
-			ItemLvl:  character.ItemLvl,
-		}
-		characters = append(characters, &charInfo)
-		err = cache.AddCharacters(streamerID, characters)
+		characters = append(characters, character.Info())
+		err = cache.AddCharacters(ctx, streamerID, characters)
 		if err != nil {
 			log.Printf("Can not update characters for %s: %v", streamerID, err)
 		}
 	}
-	err = cache.AddProfile(streamerID, character)
+	err = cache.AddProfile(ctx, streamerID, character, validators)
 	if err != nil {
 		log.Printf("Can not update profile for %s. Reason: %v", streamerID, err)
 	}
 	return nil
 }
 
-func (cache *CacheClient) ClearList(streamerID string) error {
+func (cache *CacheClient) ClearList(ctx context.Context, streamerID string) error {
 	if streamerID == "" {
 		return errors.New("StreamerID can not be empty")
 	}
-	conn := cache.pool.Get()
+	conn, err := cache.pool.GetContext(ctx)
+	if err != nil {
+		return fmt.Errorf("Can't get redis connection for %s. Reason: %v", streamerID, err)
+	}
 	defer conn.Close()
 
-	_, err := conn.Do("DEL", streamerID)
+	_, err = conn.Do("DEL", streamerID)
 	if err != nil {
 		return fmt.Errorf("Can not delete %s list from cache. Reason: %v", streamerID, err)
 	}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/salmondx/wow-twitch-extension/model"
+)
+
+// characterStorage is a part of storage.DynamoRepository used by operators
+type characterStorage interface {
+	List(ctx context.Context, streamerID string) ([]*model.CharacterInfo, error)
+	Streamers(ctx context.Context) ([]string, error)
+	CharacterCount(ctx context.Context, streamerID string) (stored, counter int, err error)
+	Recount(ctx context.Context, streamerID string) error
+	DeleteChannel(ctx context.Context, streamerID string) (int, error)
+}
+
+// characterCache is a part of cache.CacheClient used by operators
+type characterCache interface {
+	List(ctx context.Context, streamerID string) ([]*model.CharacterInfo, error)
+	ClearList(ctx context.Context, streamerID string) error
+	EvictProfiles(ctx context.Context, streamerID string, region model.Region, realm, name string) (int, error)
+	EvictChannel(ctx context.Context, streamerID string) (int, error)
+}
+
+// profileSource retrieves a profile, downloading it from Battle.Net when it's not cached
+type profileSource interface {
+	Profile(ctx context.Context, streamerID string, region model.Region, realm, name, locale string) (*model.Character, error)
+}
+
+// admin runs operator commands. resolve returns slug of a realm name
+type admin struct {
+	storage  characterStorage
+	cache    characterCache
+	profiles profileSource
+	resolve  func(ctx context.Context, region model.Region, realm string) (string, error)
+	out      io.Writer
+}
+
+var errUsage = errors.New(`Usage:
+  wowext-admin list <streamerID>
+  wowext-admin refresh <streamerID> [<region> <realm> <name>]
+  wowext-admin evict <streamerID> [<region> <realm> <name>]
+  wowext-admin delete-channel [-yes] <streamerID>
+  wowext-admin verify [-fix] [<streamerID>...]`)
+
+func (a *admin) run(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	flags := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	yes := flags.Bool("yes", false, "confirm deletion")
+	fix := flags.Bool("fix", false, "fix found issues")
+	if err := flags.Parse(args[1:]); err != nil {
+		return errUsage
+	}
+	params := flags.Args()
+
+	switch {
+	case args[0] == "list" && len(params) == 1:
+		return a.list(ctx, params[0])
+	case args[0] == "refresh" && (len(params) == 1 || len(params) == 4):
+		return a.refresh(ctx, params[0], params[1:])
+	case args[0] == "evict" && (len(params) == 1 || len(params) == 4):
+		return a.evict(ctx, params[0], params[1:])
+	case args[0] == "delete-channel" && len(params) == 1:
+		return a.deleteChannel(ctx, params[0], *yes)
+	case args[0] == "verify":
+		return a.verify(ctx, params, *fix)
+	}
+	return errUsage
+}
+
+func (a *admin) list(ctx context.Context, streamerID string) error {
+	characters, err := a.storage.List(ctx, streamerID)
+	if err != nil {
+		return err
+	}
+	cached := make(map[string]bool)
+	cachedList, err := a.cache.List(ctx, streamerID)
+	for _, character := range cachedList {
+		cached[a.characterID(ctx, character)] = true
+	}
+
+	w := tabwriter.NewWriter(a.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "REGION\tREALM\tNAME\tLEVEL\tCLASS\tCACHED")
+	for _, character := range characters {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%t\n", character.Region, character.Realm, character.Name, character.Level,
+			character.Class, cached[a.characterID(ctx, character)])
+	}
+	w.Flush()
+	if err != nil {
+		fmt.Fprintf(a.out, "List is not cached: %v\n", err)
+	}
+	return nil
+}
+
+// refresh evicts profiles and downloads them again. All characters of the channel are refreshed
+// when character is not specified
+func (a *admin) refresh(ctx context.Context, streamerID string, character []string) error {
+	refs, err := a.targets(ctx, streamerID, character)
+	if err != nil {
+		return err
+	}
+	if len(character) == 0 {
+		err = a.evictChannel(ctx, streamerID)
+	} else {
+		err = a.evictProfiles(ctx, streamerID, refs[0])
+	}
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, ref := range refs {
+		_, err := a.profiles.Profile(ctx, streamerID, ref.Region, ref.Realm, ref.Name, "")
+		if err != nil {
+			failed++
+			fmt.Fprintf(a.out, "%s %s - %s: %v\n", ref.Region, ref.Realm, ref.Name, err)
+			continue
+		}
+		fmt.Fprintf(a.out, "%s %s - %s: refreshed\n", ref.Region, ref.Realm, ref.Name)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d profiles are not refreshed", failed, len(refs))
+	}
+	return nil
+}
+
+// evict removes cached profiles of a character, or the whole channel when character is not specified
+func (a *admin) evict(ctx context.Context, streamerID string, character []string) error {
+	if len(character) == 0 {
+		return a.evictChannel(ctx, streamerID)
+	}
+	refs, err := a.targets(ctx, streamerID, character)
+	if err != nil {
+		return err
+	}
+	return a.evictProfiles(ctx, streamerID, refs[0])
+}
+
+func (a *admin) evictChannel(ctx context.Context, streamerID string) error {
+	removed, err := a.cache.EvictChannel(ctx, streamerID)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Evicted list and %d profiles of %s\n", removed, streamerID)
+	return nil
+}
+
+// evictProfiles removes cached profiles of a character with resolved realm slug
+func (a *admin) evictProfiles(ctx context.Context, streamerID string, ref model.CharacterRef) error {
+	removed, err := a.cache.EvictProfiles(ctx, streamerID, ref.Region, ref.Realm, ref.Name)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Evicted %d profiles of %s - %s\n", removed, ref.Realm, ref.Name)
+	return nil
+}
+
+// targets returns the character given as region, realm and name, or all stored characters of the channel.
+// Realms of returned characters are slugs
+func (a *admin) targets(ctx context.Context, streamerID string, character []string) ([]model.CharacterRef, error) {
+	if len(character) == 3 {
+		region, err := model.ParseRegion(character[0])
+		if err != nil {
+			return nil, err
+		}
+		realm, err := a.resolve(ctx, region, character[1])
+		if err != nil {
+			return nil, err
+		}
+		return []model.CharacterRef{{Region: region, Realm: realm, Name: character[2]}}, nil
+	}
+	characters, err := a.storage.List(ctx, streamerID)
+	if err != nil {
+		return nil, err
+	}
+	refs := make([]model.CharacterRef, 0, len(characters))
+	for _, character := range characters {
+		refs = append(refs, model.CharacterRef{Region: character.Region, Realm: a.realmSlug(ctx, character), Name: character.Name})
+	}
+	return refs, nil
+}
+
+func (a *admin) deleteChannel(ctx context.Context, streamerID string, confirmed bool) error {
+	if !confirmed {
+		characters, err := a.storage.List(ctx, streamerID)
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("%d characters, settings and cache of %s would be deleted. Add -yes to confirm", len(characters), streamerID)
+	}
+	deleted, err := a.storage.DeleteChannel(ctx, streamerID)
+	if err != nil {
+		return err
+	}
+	removed, err := a.cache.EvictChannel(ctx, streamerID)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Deleted %d characters and settings of %s, evicted %d profiles\n", deleted, streamerID, removed)
+	return nil
+}
+
+// verify compares counters and cached lists with stored characters. All channels are verified
+// when none is specified. Counters are rebuilt and stale lists are evicted with fix
+func (a *admin) verify(ctx context.Context, streamers []string, fix bool) error {
+	if len(streamers) == 0 {
+		var err error
+		streamers, err = a.storage.Streamers(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	unresolved := 0
+	for _, streamerID := range streamers {
+		countIssue, listIssues, err := a.check(ctx, streamerID)
+		if err != nil {
+			return err
+		}
+		if countIssue == "" && len(listIssues) == 0 {
+			continue
+		}
+		for _, issue := range append([]string{countIssue}, listIssues...) {
+			if issue != "" {
+				fmt.Fprintf(a.out, "%s: %s\n", streamerID, issue)
+			}
+		}
+		if !fix {
+			unresolved++
+			continue
+		}
+		if countIssue != "" {
+			if err := a.storage.Recount(ctx, streamerID); err != nil {
+				return err
+			}
+		}
+		if len(listIssues) > 0 {
+			if err := a.cache.ClearList(ctx, streamerID); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(a.out, "%s: fixed\n", streamerID)
+	}
+	fmt.Fprintf(a.out, "Verified %d channels\n", len(streamers))
+	if unresolved > 0 {
+		return fmt.Errorf("%d channels have issues. Run with -fix to fix them", unresolved)
+	}
+	return nil
+}
+
+// check returns issue of the counter enforcing the limit and differences of the cached list
+func (a *admin) check(ctx context.Context, streamerID string) (string, []string, error) {
+	stored, counter, err := a.storage.CharacterCount(ctx, streamerID)
+	if err != nil {
+		return "", nil, err
+	}
+	countIssue := ""
+	if counter >= 0 && counter != stored {
+		countIssue = fmt.Sprintf("counter is %d, but %d characters are stored", counter, stored)
+	}
+
+	characters, err := a.storage.List(ctx, streamerID)
+	if err != nil {
+		return "", nil, err
+	}
+	cached, err := a.cache.List(ctx, streamerID)
+	if err != nil {
+		// list is not cached, nothing to compare
+		return countIssue, nil, nil
+	}
+	return countIssue, a.compare(ctx, characters, cached), nil
+}
+
+// compare lists characters missing in the cached list and cached characters missing in storage
+func (a *admin) compare(ctx context.Context, stored, cached []*model.CharacterInfo) []string {
+	ids := func(characters []*model.CharacterInfo) map[string]bool {
+		result := make(map[string]bool, len(characters))
+		for _, character := range characters {
+			result[a.characterID(ctx, character)] = true
+		}
+		return result
+	}
+	storedIDs, cachedIDs := ids(stored), ids(cached)
+	issues := make([]string, 0)
+	for id := range storedIDs {
+		if !cachedIDs[id] {
+			issues = append(issues, fmt.Sprintf("%s is stored, but missing in cached list", id))
+		}
+	}
+	for id := range cachedIDs {
+		if !storedIDs[id] {
+			issues = append(issues, fmt.Sprintf("%s is cached, but not stored", id))
+		}
+	}
+	sort.Strings(issues)
+	return issues
+}
+
+func (a *admin) characterID(ctx context.Context, character *model.CharacterInfo) string {
+	return model.CharacterID(character.Region, a.realmSlug(ctx, character), character.Name)
+}
+
+// realmSlug resolves realm names of characters saved before realm slugs were introduced
+func (a *admin) realmSlug(ctx context.Context, character *model.CharacterInfo) string {
+	if character.RealmSlug != "" {
+		return character.RealmSlug
+	}
+	slug, err := a.resolve(ctx, character.Region, character.Realm)
+	if err != nil {
+		return model.Slug(character.Realm)
+	}
+	return slug
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/salmondx/wow-twitch-extension/model"
+)
+
+type memoryStorage struct {
+	characters map[string][]*model.CharacterInfo
+	counters   map[string]int
+}
+
+func (s *memoryStorage) List(ctx context.Context, streamerID string) ([]*model.CharacterInfo, error) {
+	return s.characters[streamerID], nil
+}
+
+func (s *memoryStorage) Streamers(ctx context.Context) ([]string, error) {
+	streamers := make([]string, 0, len(s.characters))
+	for streamerID := range s.characters {
+		streamers = append(streamers, streamerID)
+	}
+	return streamers, nil
+}
+
+func (s *memoryStorage) CharacterCount(ctx context.Context, streamerID string) (int, int, error) {
+	counter, ok := s.counters[streamerID]
+	if !ok {
+		counter = -1
+	}
+	return len(s.characters[streamerID]), counter, nil
+}
+
+func (s *memoryStorage) Recount(ctx context.Context, streamerID string) error {
+	s.counters[streamerID] = len(s.characters[streamerID])
+	return nil
+}
+
+func (s *memoryStorage) DeleteChannel(ctx context.Context, streamerID string) (int, error) {
+	deleted := len(s.characters[streamerID])
+	delete(s.characters, streamerID)
+	delete(s.counters, streamerID)
+	return deleted, nil
+}
+
+type memoryCache struct {
+	lists map[string][]*model.CharacterInfo
+}
+
+func (c *memoryCache) List(ctx context.Context, streamerID string) ([]*model.CharacterInfo, error) {
+	list, ok := c.lists[streamerID]
+	if !ok {
+		return nil, errors.New("not cached")
+	}
+	return list, nil
+}
+
+func (c *memoryCache) ClearList(ctx context.Context, streamerID string) error {
+	delete(c.lists, streamerID)
+	return nil
+}
+
+func (c *memoryCache) EvictProfiles(ctx context.Context, streamerID string, region model.Region, realm, name string) (int, error) {
+	return 1, nil
+}
+
+func (c *memoryCache) EvictChannel(ctx context.Context, streamerID string) (int, error) {
+	delete(c.lists, streamerID)
+	return 2, nil
+}
+
+type stubProfiles struct {
+	refreshed []string
+}
+
+func (p *stubProfiles) Profile(ctx context.Context, streamerID string, region model.Region, realm, name, locale string) (*model.Character, error) {
+	p.refreshed = append(p.refreshed, string(region)+":"+realm+":"+name)
+	return &model.Character{Name: name}, nil
+}
+
+func TestRefreshResolvesOnce(t *testing.T) {
+	profiles := &stubProfiles{}
+	resolved := 0
+	var out strings.Builder
+	a := &admin{
+		storage:  &memoryStorage{},
+		cache:    &memoryCache{},
+		profiles: profiles,
+		resolve: func(ctx context.Context, region model.Region, realm string) (string, error) {
+			resolved++
+			return "twisting-nether", nil
+		},
+		out: &out,
+	}
+
+	if err := a.run(context.Background(), []string{"refresh", "streamer", "eu", "Twisting Nether", "Salmond"}); err != nil {
+		t.Fatal(err)
+	}
+	if resolved != 1 {
+		t.Errorf("Realm is resolved %d times", resolved)
+	}
+	if len(profiles.refreshed) != 1 || profiles.refreshed[0] != "eu:twisting-nether:Salmond" {
+		t.Errorf("Wrong profiles are refreshed: %v", profiles.refreshed)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	salmond := &model.CharacterInfo{Region: model.EU, Realm: "Soulflayer", RealmSlug: "soulflayer", Name: "Salmond"}
+	// saved before realm slugs were introduced
+	legacy := &model.CharacterInfo{Region: model.EU, Realm: "Twisting Nether", Name: "Alt"}
+	deleted := &model.CharacterInfo{Region: model.EU, Realm: "Soulflayer", RealmSlug: "soulflayer", Name: "Deleted"}
+	store := &memoryStorage{
+		characters: map[string][]*model.CharacterInfo{"good": {salmond, legacy}, "bad": {salmond}},
+		counters:   map[string]int{"good": 2, "bad": 3},
+	}
+	cached := &memoryCache{lists: map[string][]*model.CharacterInfo{
+		"good": {salmond, {Region: model.EU, Realm: "Twisting Nether", RealmSlug: "twisting-nether", Name: "Alt"}},
+		"bad":  {salmond, deleted},
+	}}
+	var out strings.Builder
+	a := &admin{
+		storage: store,
+		cache:   cached,
+		resolve: func(ctx context.Context, region model.Region, realm string) (string, error) {
+			return "", errors.New("realms are unavailable")
+		},
+		out: &out,
+	}
+	ctx := context.Background()
+
+	if err := a.run(ctx, []string{"verify", "good"}); err != nil {
+		t.Errorf("Consistent channel has issues: %v\n%s", err, out.String())
+	}
+	out.Reset()
+	if err := a.run(ctx, []string{"verify"}); err == nil {
+		t.Errorf("Issues are not reported")
+	}
+	for _, issue := range []string{"bad: counter is 3, but 1 characters are stored", "bad: eu:soulflayer:deleted is cached, but not stored"} {
+		if !strings.Contains(out.String(), issue) {
+			t.Errorf("Issue %q is not reported:\n%s", issue, out.String())
+		}
+	}
+
+	if err := a.run(ctx, []string{"verify", "-fix", "bad"}); err != nil {
+		t.Fatal(err)
+	}
+	if store.counters["bad"] != 1 || cached.lists["bad"] != nil {
+		t.Errorf("Issues are not fixed: counter %d, cached %v", store.counters["bad"], cached.lists["bad"])
+	}
+}
+
+func TestDeleteChannel(t *testing.T) {
+	store := &memoryStorage{
+		characters: map[string][]*model.CharacterInfo{"streamer": {{Region: model.EU, RealmSlug: "soulflayer", Name: "Salmond"}}},
+		counters:   map[string]int{"streamer": 1},
+	}
+	var out strings.Builder
+	a := &admin{storage: store, cache: &memoryCache{}, out: &out}
+	ctx := context.Background()
+
+	if err := a.run(ctx, []string{"delete-channel", "streamer"}); err == nil {
+		t.Fatalf("Channel is deleted without confirmation")
+	}
+	if len(store.characters["streamer"]) != 1 {
+		t.Fatalf("Characters are deleted without confirmation")
+	}
+	if err := a.run(ctx, []string{"delete-channel", "-yes", "streamer"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.characters["streamer"]; ok {
+		t.Errorf("Characters are not deleted")
+	}
+}
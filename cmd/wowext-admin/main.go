@@ -0,0 +1,48 @@
+// Command wowext-admin inspects and fixes channel data in storage and cache.
+// It reads the same REDIS_ADDRESS, CLIENT_ID, CLIENT_SECRET and SHARED_RATE_LIMIT variables as the backend
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/salmondx/wow-twitch-extension/bnet"
+	"github.com/salmondx/wow-twitch-extension/cache"
+	"github.com/salmondx/wow-twitch-extension/service"
+	"github.com/salmondx/wow-twitch-extension/storage"
+)
+
+func main() {
+	log.SetFlags(0)
+	redisAddress := os.Getenv("REDIS_ADDRESS")
+	if redisAddress == "" {
+		log.Fatalln("Redis address can not be null or empty. Provide it via REDIS_ADDRESS environment variable")
+	}
+
+	redisCache := cache.New(redisAddress)
+	var limiter bnet.Limiter = bnet.NewLimiter(bnet.DefaultPerSecond, bnet.DefaultPerHour)
+	if os.Getenv("SHARED_RATE_LIMIT") == "true" {
+		limiter = cache.NewLimiter(redisCache, limiter, bnet.DefaultPerSecond, bnet.DefaultPerHour)
+	}
+	bnetClient := bnet.New(os.Getenv("CLIENT_ID"), os.Getenv("CLIENT_SECRET"), limiter)
+	dynamoStorage, err := storage.New()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	characterService := service.New(redisCache, dynamoStorage, dynamoStorage, bnetClient,
+		service.NewTooltipProviders(os.Getenv("TOOLTIP_URL")), nil, nil)
+
+	a := &admin{
+		storage:  dynamoStorage,
+		cache:    redisCache,
+		profiles: characterService,
+		resolve:  service.NewRealmCatalog(bnetClient).Resolve,
+		out:      os.Stdout,
+	}
+	// viewers of the channels are served first when the rate limit is shared
+	ctx := bnet.WithPriority(context.Background(), bnet.PriorityBackground)
+	if err := a.run(ctx, os.Args[1:]); err != nil {
+		log.Fatalln(err)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/salmondx/wow-twitch-extension/model"
+	"github.com/salmondx/wow-twitch-extension/service"
+)
+
+// channelCommands are subcommands moving a channel list between channels
+var channelCommands = map[string]bool{"export-channel": true, "import-channel": true}
+
+// runChannelCommand runs a channel subcommand. Usage:
+//
+//	export-channel <streamerID> > channel.json
+//	import-channel [-dry-run] <streamerID> <channel.json | ->
+func runChannelCommand(ctx context.Context, args []string, characterService service.CharacterService, stdin io.Reader, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("Command is not specified")
+	}
+	switch args[0] {
+	case "export-channel":
+		if len(args) != 2 {
+			return errors.New("Usage: export-channel <streamerID>")
+		}
+		export, err := characterService.ExportChannel(ctx, args[1])
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(export)
+	case "import-channel":
+		flags := flag.NewFlagSet("import-channel", flag.ContinueOnError)
+		dryRun := flags.Bool("dry-run", false, "validate export without saving anything")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		if flags.NArg() != 2 {
+			return errors.New("Usage: import-channel [-dry-run] <streamerID> <file>. Use - to read from stdin")
+		}
+		export, err := readExport(flags.Arg(1), stdin)
+		if err != nil {
+			return err
+		}
+		channelImport, err := characterService.ImportChannel(ctx, flags.Arg(0), export, *dryRun)
+		if err != nil {
+			return err
+		}
+		printImport(stdout, channelImport)
+		return nil
+	}
+	return fmt.Errorf("Unknown command %s", args[0])
+}
+
+func readExport(path string, stdin io.Reader) (*model.ChannelExport, error) {
+	input := stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		input = file
+	}
+	var export model.ChannelExport
+	if err := json.NewDecoder(input).Decode(&export); err != nil {
+		return nil, fmt.Errorf("Can't read export %s: %v", path, err)
+	}
+	return &export, nil
+}
+
+func printImport(w io.Writer, channelImport *model.ChannelImport) {
+	verb := "added"
+	if channelImport.DryRun {
+		verb = "can be added"
+	}
+	for _, result := range channelImport.Characters {
+		if result.Err != nil {
+			fmt.Fprintf(w, "%s %s - %s: %v\n", result.Region, result.Realm, result.Name, result.Err)
+			continue
+		}
+		fmt.Fprintf(w, "%s %s - %s: %s\n", result.Region, result.Realm, result.Name, verb)
+	}
+	if channelImport.Settings != nil {
+		fmt.Fprintf(w, "Tooltip provider: %s\n", channelImport.Settings.TooltipProvider)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestChannelCommands(t *testing.T) {
+	characterService := &stubService{}
+	ctx := context.Background()
+
+	var exported strings.Builder
+	if err := runChannelCommand(ctx, []string{"export-channel", "testing_streamer"}, characterService, nil, &exported); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(exported.String(), `"Version": 1`) {
+		t.Errorf("Export is not written: %s", exported.String())
+	}
+
+	export := `{"Characters":[{"Region":"eu","RealmSlug":"soulflayer","Name":"Salmond"}]}`
+	var output strings.Builder
+	args := []string{"import-channel", "-dry-run", "testing_streamer", "-"}
+	if err := runChannelCommand(ctx, args, characterService, strings.NewReader(export), &output); err != nil {
+		t.Fatal(err)
+	}
+	if characterService.calls[1] != "import channel testing_streamer true eu:soulflayer:Salmond" {
+		t.Errorf("Wrong call %q", characterService.calls[1])
+	}
+	if output.String() != "eu soulflayer - Salmond: can be added\n" {
+		t.Errorf("Wrong output %q", output.String())
+	}
+
+	if err := runChannelCommand(ctx, []string{"import-channel", "testing_streamer"}, characterService, nil, &output); err == nil {
+		t.Errorf("Missing file is accepted")
+	}
+}
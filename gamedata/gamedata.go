@@ -0,0 +1,280 @@
+// Package gamedata describes WoW classes, specs, races, factions, equipment slots and item stats.
+// Names are translated to every Battle.Net locale, data is bundled as JSON files
+package gamedata
+
+import (
+	"embed"
+	"encoding/json"
+	"log"
+	"strings"
+)
+
+//go:embed *.json
+var files embed.FS
+
+// DefaultLocale is used when a name has no translation to requested locale
+const DefaultLocale = "en_US"
+
+// Role is a group role of a spec
+type Role string
+
+const (
+	Tank   Role = "tank"
+	Healer Role = "healer"
+	Damage Role = "damage"
+)
+
+// ArmorType is the heaviest armor a class wears
+type ArmorType string
+
+const (
+	Cloth   ArmorType = "cloth"
+	Leather ArmorType = "leather"
+	Mail    ArmorType = "mail"
+	Plate   ArmorType = "plate"
+)
+
+// Names maps locales like de_DE to names
+type Names map[string]string
+
+// In returns name in locale. Missing locales fall back to a locale of the same language, so en_GB
+// is served as en_US and pt_PT as pt_BR, and then to default locale
+func (n Names) In(locale string) string {
+	if name, ok := n[locale]; ok {
+		return name
+	}
+	language := strings.SplitN(locale, "_", 2)[0]
+	fallback := DefaultLocale
+	for l := range n {
+		// the first locale in alphabetical order, so the choice is stable
+		if strings.SplitN(l, "_", 2)[0] == language && (fallback == DefaultLocale || l < fallback) {
+			fallback = l
+		}
+	}
+	return n[fallback]
+}
+
+// Class is a playable class. Key is stable, like DEATH_KNIGHT
+type Class struct {
+	ID    int
+	Key   string
+	Armor ArmorType
+	// Color is the official class color, like #C41E3A
+	Color string
+	Names Names
+}
+
+// Spec is a class specialization. Key is stable within a class, like FROST
+type Spec struct {
+	ID    int
+	Class int
+	// Order is a position of the spec in class, Battle.Net community API identifies specs by it
+	Order int
+	Key   string
+	Role  Role
+	Names Names
+}
+
+// Slot is an equipment slot. Key is stable, like MAIN_HAND
+type Slot struct {
+	Key   string
+	Names Names
+}
+
+// Race is a playable race. Pandaren, Dracthyr and Earthen have an id per faction, but the same key
+type Race struct {
+	ID    int
+	Key   string
+	Names Names
+}
+
+// Faction is a character faction. Icon is a crest icon name, neutral faction has none
+type Faction struct {
+	ID    int
+	Key   string
+	Icon  string
+	Names Names
+}
+
+// StatKind groups item stats the way game tooltips do
+type StatKind string
+
+const (
+	Primary   StatKind = "primary"
+	Secondary StatKind = "secondary"
+	Tertiary  StatKind = "tertiary"
+)
+
+// Stat is an item stat. Key is stable, like CRIT
+type Stat struct {
+	ID    int
+	Key   string
+	Kind  StatKind
+	Names Names
+}
+
+type specRef struct {
+	class int
+	order int
+}
+
+var (
+	classes      = make(map[int]Class)
+	specs        = make(map[int]Spec)
+	specsByOrder = make(map[specRef]Spec)
+	slots        = make(map[string]Slot)
+	races        = make(map[int]Race)
+	factions     = make(map[int]Faction)
+	stats        = make(map[int]Stat)
+)
+
+func init() {
+	var classList []Class
+	var specList []Spec
+	var slotList []Slot
+	var raceList []Race
+	var factionList []Faction
+	var statList []Stat
+	readFile("classes.json", &classList)
+	readFile("specs.json", &specList)
+	readFile("slots.json", &slotList)
+	readFile("races.json", &raceList)
+	readFile("factions.json", &factionList)
+	readFile("stats.json", &statList)
+
+	for _, class := range classList {
+		classes[class.ID] = class
+	}
+	for _, spec := range specList {
+		specs[spec.ID] = spec
+		specsByOrder[specRef{spec.Class, spec.Order}] = spec
+	}
+	for _, slot := range slotList {
+		slots[slot.Key] = slot
+	}
+	for _, race := range raceList {
+		races[race.ID] = race
+	}
+	for _, faction := range factionList {
+		factions[faction.ID] = faction
+	}
+	for _, stat := range statList {
+		stats[stat.ID] = stat
+	}
+}
+
+func readFile(name string, v interface{}) {
+	data, err := files.ReadFile(name)
+	if err == nil {
+		err = json.Unmarshal(data, v)
+	}
+	if err != nil {
+		log.Fatalf("Can't read game data %s: %v", name, err)
+	}
+}
+
+// ClassByID returns class by Battle.Net class id
+func ClassByID(id int) (Class, bool) {
+	class, ok := classes[id]
+	return class, ok
+}
+
+// SpecByID returns spec by Battle.Net spec id
+func SpecByID(id int) (Spec, bool) {
+	spec, ok := specs[id]
+	return spec, ok
+}
+
+// SpecByOrder returns spec given by its position in class
+func SpecByOrder(classID, order int) (Spec, bool) {
+	spec, ok := specsByOrder[specRef{classID, order}]
+	return spec, ok
+}
+
+// SlotByKey returns equipment slot by its key, like MAIN_HAND
+func SlotByKey(key string) (Slot, bool) {
+	slot, ok := slots[key]
+	return slot, ok
+}
+
+// RaceByID returns race by Battle.Net race id
+func RaceByID(id int) (Race, bool) {
+	race, ok := races[id]
+	return race, ok
+}
+
+// FactionByID returns faction by Battle.Net community API faction id: 0 is Alliance, 1 is Horde
+func FactionByID(id int) (Faction, bool) {
+	faction, ok := factions[id]
+	return faction, ok
+}
+
+// FactionByKey returns faction by its key, like HORDE. Profile API names factions by key
+func FactionByKey(key string) (Faction, bool) {
+	for _, faction := range factions {
+		if faction.Key == key {
+			return faction, true
+		}
+	}
+	return Faction{}, false
+}
+
+// StatByID returns item stat by Battle.Net stat id
+func StatByID(id int) (Stat, bool) {
+	stat, ok := stats[id]
+	return stat, ok
+}
+
+// Classes returns all playable classes
+func Classes() []Class {
+	result := make([]Class, 0, len(classes))
+	for _, class := range classes {
+		result = append(result, class)
+	}
+	return result
+}
+
+// Specs returns all specs of all classes
+func Specs() []Spec {
+	result := make([]Spec, 0, len(specs))
+	for _, spec := range specs {
+		result = append(result, spec)
+	}
+	return result
+}
+
+// Slots returns all equipment slots
+func Slots() []Slot {
+	result := make([]Slot, 0, len(slots))
+	for _, slot := range slots {
+		result = append(result, slot)
+	}
+	return result
+}
+
+// Races returns all playable races
+func Races() []Race {
+	result := make([]Race, 0, len(races))
+	for _, race := range races {
+		result = append(result, race)
+	}
+	return result
+}
+
+// Factions returns all factions
+func Factions() []Faction {
+	result := make([]Faction, 0, len(factions))
+	for _, faction := range factions {
+		result = append(result, faction)
+	}
+	return result
+}
+
+// Stats returns all item stats
+func Stats() []Stat {
+	result := make([]Stat, 0, len(stats))
+	for _, stat := range stats {
+		result = append(result, stat)
+	}
+	return result
+}
@@ -0,0 +1,113 @@
+package gamedata
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/salmondx/wow-twitch-extension/bnet"
+	"github.com/salmondx/wow-twitch-extension/model"
+)
+
+func TestClasses(t *testing.T) {
+	classSpecs := make(map[int]int)
+	for _, spec := range Specs() {
+		if _, ok := ClassByID(spec.Class); !ok {
+			t.Errorf("Spec %d of unknown class %d", spec.ID, spec.Class)
+		}
+		if spec.Role != Tank && spec.Role != Healer && spec.Role != Damage {
+			t.Errorf("Spec %d has unknown role %q", spec.ID, spec.Role)
+		}
+		if byOrder, _ := SpecByOrder(spec.Class, spec.Order); byOrder.ID != spec.ID {
+			t.Errorf("Spec %d shares order %d with %d", spec.ID, spec.Order, byOrder.ID)
+		}
+		classSpecs[spec.Class]++
+	}
+	for _, class := range Classes() {
+		switch class.Armor {
+		case Cloth, Leather, Mail, Plate:
+		default:
+			t.Errorf("%s has unknown armor %q", class.Key, class.Armor)
+		}
+		if len(class.Color) != 7 || class.Color[0] != '#' {
+			t.Errorf("%s has invalid color %q", class.Key, class.Color)
+		}
+		if classSpecs[class.ID] < 2 {
+			t.Errorf("%s has %d specs", class.Key, classSpecs[class.ID])
+		}
+	}
+	if spec, _ := SpecByID(250); spec.Key != "BLOOD" || spec.Role != Tank {
+		t.Errorf("Wrong Blood spec %v", spec)
+	}
+}
+
+func TestNames(t *testing.T) {
+	names := Names{"en_US": "Paladin", "pt_BR": "Paladino", "es_ES": "Paladín", "es_MX": "Paladín"}
+	var tests = []struct {
+		locale string
+		name   string
+	}{
+		{"en_US", "Paladin"},
+		{"en_GB", "Paladin"},
+		{"pt_PT", "Paladino"},
+		{"es_AR", "Paladín"},
+		{"ko_KR", "Paladin"},
+		{"", "Paladin"},
+	}
+	for _, tt := range tests {
+		if name := names.In(tt.locale); name != tt.name {
+			t.Errorf("%s: %s, expected %s", tt.locale, name, tt.name)
+		}
+	}
+}
+
+// TestLocales checks every name is translated to every Battle.Net locale or its language
+func TestLocales(t *testing.T) {
+	var names []Names
+	for _, class := range Classes() {
+		names = append(names, class.Names)
+	}
+	for _, spec := range Specs() {
+		names = append(names, spec.Names)
+	}
+	for _, slot := range Slots() {
+		names = append(names, slot.Names)
+	}
+	for _, race := range Races() {
+		names = append(names, race.Names)
+	}
+	for _, faction := range Factions() {
+		names = append(names, faction.Names)
+	}
+	for _, stat := range Stats() {
+		names = append(names, stat.Names)
+	}
+
+	for _, region := range model.Regions {
+		for _, locale := range bnet.Locales(region) {
+			for _, n := range names {
+				if !translated(n, locale) {
+					t.Errorf("%s is not translated to %s", n[DefaultLocale], locale)
+				}
+			}
+		}
+	}
+}
+
+func TestSlots(t *testing.T) {
+	items := reflect.TypeOf(bnet.Items{})
+	for i := 0; i < items.NumField(); i++ {
+		slot := items.Field(i).Tag.Get("slot")
+		if _, ok := SlotByKey(slot); slot != "" && !ok {
+			t.Errorf("Slot %s of %s is not described", slot, items.Field(i).Name)
+		}
+	}
+}
+
+func translated(names Names, locale string) bool {
+	for l, name := range names {
+		if name != "" && l[:2] == locale[:2] {
+			return true
+		}
+	}
+	return false
+}
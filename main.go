@@ -1,16 +1,25 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha1"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/dgrijalva/jwt-go"
 
+	"github.com/salmondx/wow-twitch-extension/assets"
 	"github.com/salmondx/wow-twitch-extension/bnet"
 	"github.com/salmondx/wow-twitch-extension/cache"
 	"github.com/salmondx/wow-twitch-extension/model"
@@ -25,6 +34,8 @@ type RequestParameters struct {
 	StreamerID string
 	Region     string
 	Role       string
+	// Locales preferred by the viewer, most preferred first
+	Locales []string
 }
 
 type ErrorMessage struct {
@@ -43,12 +54,38 @@ func (e HttpError) Error() string {
 
 const StageDev = "dev"
 
+// Deadlines for a single request. List refreshes every character from Battle.Net, so it gets more time
+const (
+	profileTimeout = 10 * time.Second
+	listTimeout    = 20 * time.Second
+	updateTimeout  = 10 * time.Second
+)
+
+// How long browsers and CDN may reuse responses without revalidation. Zero disables caching headers,
+// revalidate keeps the ETag but requires revalidation on every use
+const (
+	profileMaxAge = 5 * time.Minute
+	listMaxAge    = time.Minute
+	noCache       = 0
+	revalidate    = -1
+)
+
 // Partial commit, rewrite using DI
 var (
-	twitchSecret     []byte
-	clientSecret     = os.Getenv("CLIENT_SECRET")
-	redisAddress     = os.Getenv("REDIS_ADDRESS")
-	stage            = os.Getenv("STAGE")
+	twitchSecret    []byte
+	clientID        = os.Getenv("CLIENT_ID")
+	clientSecret    = os.Getenv("CLIENT_SECRET")
+	redisAddress    = os.Getenv("REDIS_ADDRESS")
+	sharedRateLimit = os.Getenv("SHARED_RATE_LIMIT") == "true"
+	stage           = os.Getenv("STAGE")
+	tooltipURL      = os.Getenv("TOOLTIP_URL")
+	// image proxy is enabled with public URL of the backend. Assets are kept on disk if directory is set,
+	// in Redis under a size limit otherwise
+	assetsURL = os.Getenv("ASSETS_URL")
+	assetsDir = os.Getenv("ASSETS_DIR")
+	// account import is enabled with public URL of the import callback, registered as Battle.Net redirect URL
+	importCallbackURL = os.Getenv("IMPORT_CALLBACK_URL")
+	// v1 clients expect code 105 for these errors
 	badRequest       = HttpError{"Missing required parameters", http.StatusBadRequest}
 	methodNotAllowed = HttpError{"Method not allowed", http.StatusMethodNotAllowed}
 	wrongRole        = HttpError{"Only streamer is allowed to update characters list", http.StatusForbidden}
@@ -57,65 +94,34 @@ var (
 	characterLimit    = ErrorMessage{101, "Character limit reached. Delete character to add a new one"}
 	unknownError      = ErrorMessage{102, "Unknown error occurred. Try again later"}
 	missingParameters = ErrorMessage{103, "Required parameters were not provided"}
+	// reason of the following errors is replaced with details
+	characterDuplicate  = ErrorMessage{104, "Character already exists"}
+	invalidRequest      = ErrorMessage{105, "Request is invalid"}
+	upstreamUnavailable = ErrorMessage{106, "Battle.Net is unavailable. Try again later"}
+	rateLimited         = ErrorMessage{107, "Too many requests to Battle.Net. Try again later"}
+	invalidRegion       = ErrorMessage{108, "Region is not supported"}
+	realmNotFound       = ErrorMessage{109, "No realm with such name in the region"}
+	forbidden           = ErrorMessage{110, "Operation is not allowed"}
+	requestTimeout      = ErrorMessage{111, "Request took too long. Try again later"}
+	guildNotFound       = ErrorMessage{112, "No guild with such name and realm pair"}
 )
 
-func requestHandler(h func(string, RequestParameters, service.CharacterService) (interface{}, error),
+func requestHandler(h func(context.Context, string, RequestParameters, service.CharacterService) (interface{}, error),
 	characterService service.CharacterService,
-	successCode int) http.HandlerFunc {
+	successCode int,
+	timeout time.Duration,
+	maxAge time.Duration) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodOptions {
-			w.Header().Add("Access-Control-Allow-Origin", "*")
-			w.Header().Add("Access-Control-Allow-Methods", "GET, POST, OPTIONS, DELETE")
-			w.Header().Add("Access-Control-Allow-Headers", "Authorization")
+		if preflight(w, r) {
 			return
 		}
 
-		w.Header().Add("Access-Control-Allow-Methods", "GET, POST, OPTIONS, DELETE")
-		w.Header().Add("Access-Control-Allow-Headers", "Authorization")
-		w.Header().Add("Access-Control-Allow-Origin", "*")
-
-		rawToken := r.Header.Get("Authorization")
-		if rawToken == "" {
+		streamerID, role, ok := authenticate(r)
+		if !ok {
 			w.WriteHeader(http.StatusUnauthorized)
 			return
 		}
 
-		var streamerID, role string
-		// production mode. should check token authorization
-		if stage != StageDev {
-
-			token, err := jwt.Parse(rawToken, func(token *jwt.Token) (interface{}, error) {
-				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-					return nil, errors.New("Unexpected signing method")
-				}
-				return twitchSecret, nil
-			})
-			if err != nil {
-				log.Printf("[INFO] Unauthorized: %v", err)
-				w.WriteHeader(http.StatusUnauthorized)
-				return
-			}
-			claims, ok := token.Claims.(jwt.MapClaims)
-			if !ok || !token.Valid {
-				log.Printf("[INFO] Invalid token")
-				w.WriteHeader(http.StatusUnauthorized)
-				return
-			}
-
-			var roleOk bool
-			streamerID, ok = claims["channel_id"].(string)
-			role, roleOk = claims["role"].(string)
-			if !ok || !roleOk {
-				log.Printf("[WARN] Can't get channel_id or role property")
-				w.WriteHeader(http.StatusUnauthorized)
-				return
-			}
-
-		} else {
-			streamerID = "testing_streamer"
-			role = "broadcaster"
-		}
-
 		w.Header().Add("Content-Type", "application/json")
 
 		queryParams := r.URL.Query()
@@ -129,66 +135,274 @@ func requestHandler(h func(string, RequestParameters, service.CharacterService)
 			Region:     region,
 			StreamerID: streamerID,
 			Role:       role,
+			Locales:    localePreferences(r),
 		}
-		data, err := h(r.Method, parameters, characterService)
-		if err != nil {
-			errorMessage, status := handleError(err)
-			w.WriteHeader(status)
-			json.NewEncoder(w).Encode(errorMessage)
-			return
+		// request context is cancelled when the viewer closes the panel
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		data, err := h(ctx, r.Method, parameters, characterService)
+		writeResult(w, r, data, err, successCode, callerMaxAge(role, maxAge))
+	}
+}
+
+// preflight adds CORS headers and answers preflight requests. Returns true if request is already answered
+func preflight(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method == http.MethodOptions {
+		w.Header().Add("Access-Control-Allow-Origin", "*")
+		w.Header().Add("Access-Control-Allow-Methods", "GET, POST, PUT, OPTIONS, DELETE")
+		w.Header().Add("Access-Control-Allow-Headers", "Authorization, Content-Type, If-None-Match, Accept-Language")
+		return true
+	}
+
+	w.Header().Add("Access-Control-Allow-Methods", "GET, POST, PUT, OPTIONS, DELETE")
+	w.Header().Add("Access-Control-Allow-Headers", "Authorization, Content-Type, If-None-Match, Accept-Language")
+	w.Header().Add("Access-Control-Expose-Headers", "ETag")
+	w.Header().Add("Access-Control-Allow-Origin", "*")
+	return false
+}
+
+// localePreferences returns locales requested by the viewer. Explicit locale query parameter
+// goes first, followed by Accept-Language languages ordered by their quality
+func localePreferences(r *http.Request) []string {
+	var preferences []string
+	if locale := r.URL.Query().Get("locale"); locale != "" {
+		preferences = append(preferences, locale)
+	}
+	return append(preferences, acceptedLanguages(r.Header.Get("Accept-Language"))...)
+}
+
+// acceptedLanguages parses Accept-Language header. Languages with zero quality and wildcard are skipped
+func acceptedLanguages(header string) []string {
+	type language struct {
+		tag     string
+		quality float64
+	}
+	var languages []language
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		tag := strings.TrimSpace(fields[0])
+		if tag == "" || tag == "*" {
+			continue
 		}
+		quality := 1.0
+		for _, field := range fields[1:] {
+			field = strings.TrimSpace(field)
+			if strings.HasPrefix(field, "q=") {
+				q, err := strconv.ParseFloat(field[2:], 64)
+				if err != nil {
+					q = 0
+				}
+				quality = q
+			}
+		}
+		if quality <= 0 {
+			continue
+		}
+		languages = append(languages, language{tag, quality})
+	}
+	sort.SliceStable(languages, func(i, j int) bool {
+		return languages[i].quality > languages[j].quality
+	})
+	tags := make([]string, len(languages))
+	for i, l := range languages {
+		tags[i] = l.tag
+	}
+	return tags
+}
+
+// authenticate returns channel and role of the Twitch extension token owner
+func authenticate(r *http.Request) (string, string, bool) {
+	rawToken := r.Header.Get("Authorization")
+	if rawToken == "" {
+		return "", "", false
+	}
+
+	// development mode. token is not verified
+	if stage == StageDev {
+		return "testing_streamer", "broadcaster", true
+	}
+
+	token, err := jwt.Parse(rawToken, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("Unexpected signing method")
+		}
+		return twitchSecret, nil
+	})
+	if err != nil {
+		log.Printf("[INFO] Unauthorized: %v", err)
+		return "", "", false
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		log.Printf("[INFO] Invalid token")
+		return "", "", false
+	}
+
+	streamerID, ok := claims["channel_id"].(string)
+	role, roleOk := claims["role"].(string)
+	if !ok || !roleOk {
+		log.Printf("[WARN] Can't get channel_id or role property")
+		return "", "", false
+	}
+	return streamerID, role, true
+}
+
+// callerMaxAge makes broadcasters revalidate responses, so they see their own changes of the channel right away
+func callerMaxAge(role string, maxAge time.Duration) time.Duration {
+	if role == "broadcaster" && maxAge > 0 {
+		return revalidate
+	}
+	return maxAge
+}
+
+// writeResult writes handler result or error as JSON. Cachable responses get ETag and Cache-Control
+func writeResult(w http.ResponseWriter, r *http.Request, data interface{}, err error, successCode int, maxAge time.Duration) {
+	if err != nil {
+		errorMessage, status, retryAfter := handleError(err)
+		if retryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(errorMessage)
+		return
+	}
+	if data == nil {
 		w.WriteHeader(successCode)
-		if data != nil {
-			json.NewEncoder(w).Encode(data)
+		return
+	}
+	var body bytes.Buffer
+	json.NewEncoder(&body).Encode(data)
+	if maxAge > 0 || maxAge == revalidate {
+		// responses differ per channel and viewer language, so cached copies are keyed by both
+		tag := etag(body.Bytes())
+		w.Header().Set("ETag", tag)
+		if maxAge == revalidate {
+			w.Header().Set("Cache-Control", "no-cache")
+		} else {
+			w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+		}
+		w.Header().Set("Vary", "Authorization, Accept-Language")
+		if etagMatches(r.Header.Get("If-None-Match"), tag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
 		}
 	}
+	w.WriteHeader(successCode)
+	w.Write(body.Bytes())
 }
 
-func handleError(err error) (ErrorMessage, int) {
-	var errorMessage ErrorMessage
-	var status int
-	switch err.(type) {
-	case model.CharacterNotFound:
+// etag is a strong entity tag of a serialized response
+func etag(body []byte) string {
+	return fmt.Sprintf("\"%x\"", sha1.Sum(body))
+}
+
+// etagMatches checks If-None-Match header value against response entity tag
+func etagMatches(ifNoneMatch, tag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// handleError maps error to a stable API error code and HTTP status.
+// Retry-After is returned for errors that are expected to go away with time
+func handleError(err error) (ErrorMessage, int, time.Duration) {
+	var (
+		notFound    model.CharacterNotFound
+		noGuild     model.GuildNotFound
+		limit       model.CharacterLimitError
+		duplicate   model.CharacterDuplicateError
+		validation  model.ValidationError
+		denied      model.ForbiddenError
+		region      model.InvalidRegionError
+		realm       model.RealmNotFoundError
+		unavailable model.UpstreamUnavailableError
+		throttled   model.RateLimitedError
+		httpErr     HttpError
+	)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		log.Printf("[WARN] Request timed out: %v", err)
+		return requestTimeout, http.StatusGatewayTimeout, 0
+	case errors.As(err, &notFound):
 		log.Printf("[INFO] Character not found: %v", err)
-		errorMessage = characterNotFound
-		status = http.StatusNotFound
-	case model.CharacterLimitError:
+		return characterNotFound, http.StatusNotFound, 0
+	case errors.As(err, &noGuild):
+		log.Printf("[INFO] Guild not found: %v", err)
+		return guildNotFound, http.StatusNotFound, 0
+	case errors.As(err, &limit):
 		log.Printf("[INFO] Character limit reached. %v", err)
-		errorMessage = characterLimit
-		status = http.StatusConflict
-	case model.CharacterDuplicateError:
+		return characterLimit, http.StatusConflict, 0
+	case errors.As(err, &duplicate):
 		log.Printf("[INFO] Character duplicate: %v", err)
-		errorMessage = ErrorMessage{104, err.Error()}
-		status = http.StatusConflict
-	case HttpError:
-		httpErr := err.(HttpError)
+		return ErrorMessage{characterDuplicate.Code, duplicate.S}, http.StatusConflict, 0
+	case errors.As(err, &validation):
+		log.Printf("[INFO] Validation failed: %v", err)
+		return ErrorMessage{missingParameters.Code, validation.S}, http.StatusBadRequest, 0
+	case errors.As(err, &denied):
+		log.Printf("[INFO] Forbidden: %v", err)
+		return ErrorMessage{forbidden.Code, denied.S}, http.StatusForbidden, 0
+	case errors.As(err, &region):
+		log.Printf("[INFO] Invalid region: %v", err)
+		return ErrorMessage{invalidRegion.Code, region.S}, http.StatusBadRequest, 0
+	case errors.As(err, &realm):
+		log.Printf("[INFO] Realm not found: %v", err)
+		return realmNotFound, http.StatusNotFound, 0
+	case errors.As(err, &unavailable):
+		log.Printf("[WARN] %v", err)
+		return upstreamUnavailable, http.StatusServiceUnavailable, unavailable.RetryAfter
+	case errors.As(err, &throttled):
+		log.Printf("[WARN] %v", err)
+		return rateLimited, http.StatusTooManyRequests, throttled.RetryAfter
+	case errors.As(err, &httpErr):
 		log.Printf("[INFO] %v", httpErr.S)
-		errorMessage = ErrorMessage{105, httpErr.S}
-		status = httpErr.Code
-	default:
-		log.Printf("[ERROR] %v", err)
-		errorMessage = unknownError
-		status = http.StatusInternalServerError
-	}
-	return errorMessage, status
+		return ErrorMessage{invalidRequest.Code, httpErr.S}, httpErr.Code, 0
+	}
+	log.Printf("[ERROR] %v", err)
+	return unknownError, http.StatusInternalServerError, 0
 }
 
-func profileHandler(method string, parameters RequestParameters, characterService service.CharacterService) (interface{}, error) {
+func profileHandler(ctx context.Context, method string, parameters RequestParameters, characterService service.CharacterService) (interface{}, error) {
 	if method != http.MethodGet {
 		return nil, methodNotAllowed
 	}
 	if missingRequiredParameters(parameters) {
 		return nil, badRequest
 	}
+	region, err := model.ParseRegion(parameters.Region)
+	if err != nil {
+		return nil, err
+	}
 	log.Printf("[INFO] Profile for %v - %v", parameters.Realm, parameters.Name)
-	profile, err := characterService.Profile(parameters.StreamerID, parameters.Region, parameters.Realm, parameters.Name)
+	locale := bnet.ResolveLocale(region, parameters.Locales...)
+	profile, err := characterService.Profile(ctx, parameters.StreamerID, region, parameters.Realm, parameters.Name, locale)
 	if err != nil {
 		return nil, err
 	}
 	return profile, nil
 }
 
-func listHandler(method string, parameters RequestParameters, characterService service.CharacterService) (interface{}, error) {
+func guildHandler(ctx context.Context, method string, parameters RequestParameters, characterService service.CharacterService) (interface{}, error) {
+	if method != http.MethodGet {
+		return nil, methodNotAllowed
+	}
+	if missingRequiredParameters(parameters) {
+		return nil, badRequest
+	}
+	region, err := model.ParseRegion(parameters.Region)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[INFO] Guild %v - %v", parameters.Realm, parameters.Name)
+	locale := bnet.ResolveLocale(region, parameters.Locales...)
+	return characterService.Guild(ctx, region, parameters.Realm, parameters.Name, locale)
+}
+
+func listHandler(ctx context.Context, method string, parameters RequestParameters, characterService service.CharacterService) (interface{}, error) {
 	if method != http.MethodGet {
 		return nil, methodNotAllowed
 	}
@@ -196,14 +410,14 @@ func listHandler(method string, parameters RequestParameters, characterService s
 		return nil, badRequest
 	}
 	log.Printf("[INFO] Character list for %s", parameters.StreamerID)
-	characters, err := characterService.List(parameters.StreamerID)
+	characters, err := characterService.List(ctx, parameters.StreamerID)
 	if err != nil {
 		return nil, err
 	}
 	return characters, nil
 }
 
-func addCharacterHandler(method string, parameters RequestParameters, chacterService service.CharacterService) (interface{}, error) {
+func addCharacterHandler(ctx context.Context, method string, parameters RequestParameters, chacterService service.CharacterService) (interface{}, error) {
 	if method != http.MethodPost {
 		return nil, methodNotAllowed
 	}
@@ -213,16 +427,20 @@ func addCharacterHandler(method string, parameters RequestParameters, chacterSer
 	if missingRequiredParameters(parameters) {
 		return nil, badRequest
 	}
+	region, err := model.ParseRegion(parameters.Region)
+	if err != nil {
+		return nil, err
+	}
 
 	log.Printf("[INFO] Adding character for %s: %s - %s", parameters.StreamerID, parameters.Realm, parameters.Name)
-	err := chacterService.Add(parameters.StreamerID, parameters.Region, parameters.Realm, parameters.Name)
+	err = chacterService.Add(ctx, parameters.StreamerID, region, parameters.Realm, parameters.Name)
 	if err != nil {
 		return nil, err
 	}
 	return nil, nil
 }
 
-func deleteCharacterHandler(method string, parameters RequestParameters, chacterService service.CharacterService) (interface{}, error) {
+func deleteCharacterHandler(ctx context.Context, method string, parameters RequestParameters, chacterService service.CharacterService) (interface{}, error) {
 	if method != http.MethodDelete {
 		return nil, methodNotAllowed
 	}
@@ -232,15 +450,63 @@ func deleteCharacterHandler(method string, parameters RequestParameters, chacter
 	if missingRequiredParameters(parameters) {
 		return nil, badRequest
 	}
+	region, err := model.ParseRegion(parameters.Region)
+	if err != nil {
+		return nil, err
+	}
 
 	log.Printf("[INFO] Deleting character for %s: %s - %s", parameters.StreamerID, parameters.Realm, parameters.Name)
-	err := chacterService.Delete(parameters.StreamerID, parameters.Region, parameters.Realm, parameters.Name)
+	err = chacterService.Delete(ctx, parameters.StreamerID, region, parameters.Realm, parameters.Name)
 	if err != nil {
 		return nil, err
 	}
 	return nil, nil
 }
 
+// importCallbackHandler completes account import when Battle.Net redirects the broadcaster back.
+// It's opened in a browser tab, so it answers with plain text instead of JSON
+func importCallbackHandler(characterService service.CharacterService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		query := r.URL.Query()
+		ctx, cancel := context.WithTimeout(r.Context(), listTimeout)
+		defer cancel()
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		err := characterService.CompleteImport(ctx, query.Get("state"), query.Get("code"))
+		if err != nil {
+			errorMessage, status, _ := handleError(err)
+			w.WriteHeader(status)
+			fmt.Fprintf(w, "Characters can't be imported: %s", errorMessage.Reason)
+			return
+		}
+		fmt.Fprint(w, "Battle.Net account is connected. Return to the extension configuration to pick characters")
+	}
+}
+
+// devAccountCharacters are characters of every account in development mode
+func devAccountCharacters() []bnet.AccountCharacter {
+	return []bnet.AccountCharacter{
+		{Name: "Salmond", Realm: bnet.Realm{Name: "Soulflayer", Slug: "soulflayer"}, PlayableClass: bnet.Ref{ID: 2}, PlayableRace: bnet.Ref{ID: 10},
+			Gender: bnet.TypeRef{Type: "MALE"}, Faction: bnet.TypeRef{Type: "HORDE"}, Level: 80},
+		{Name: "Salmondh", Realm: bnet.Realm{Name: "Soulflayer", Slug: "soulflayer"}, PlayableClass: bnet.Ref{ID: 12}, PlayableRace: bnet.Ref{ID: 10},
+			Gender: bnet.TypeRef{Type: "FEMALE"}, Faction: bnet.TypeRef{Type: "HORDE"}, Level: 70},
+	}
+}
+
+// registerV1 registers API kept for released extension versions. Returns registered patterns
+func registerV1(mux *http.ServeMux, characterService service.CharacterService) []string {
+	mux.HandleFunc("/profile", requestHandler(profileHandler, characterService, http.StatusOK, profileTimeout, profileMaxAge))
+	mux.HandleFunc("/list", requestHandler(listHandler, characterService, http.StatusOK, listTimeout, listMaxAge))
+	mux.HandleFunc("/list/add", requestHandler(addCharacterHandler, characterService, http.StatusCreated, updateTimeout, noCache))
+	mux.HandleFunc("/list/delete", requestHandler(deleteCharacterHandler, characterService, http.StatusNoContent, updateTimeout, noCache))
+	mux.HandleFunc("/guild", requestHandler(guildHandler, characterService, http.StatusOK, profileTimeout, profileMaxAge))
+	return []string{"/profile", "/list", "/list/add", "/list/delete", "/guild"}
+}
+
 func missingRequiredParameters(parameters RequestParameters) bool {
 	return parameters.StreamerID == "" || parameters.Realm == "" || parameters.Name == "" || parameters.Region == ""
 }
@@ -249,6 +515,9 @@ func main() {
 	if clientSecret == "" {
 		log.Fatalln("Battle net client secret can not be null or empty! Provide it via CLIENT_SECRET environment variable")
 	}
+	if clientID == "" {
+		log.Println("[WARN] Battle net client id is not provided via CLIENT_ID environment variable. Talent trees are not available")
+	}
 	if redisAddress == "" {
 		log.Fatalln("Redis address can not be null or empty. Provide it via REDIS_ADDRESS environment variable")
 	}
@@ -262,17 +531,66 @@ func main() {
 	}
 	twitchSecret = []byte(s)
 
-	bnetClient := bnet.New(clientSecret)
 	redisCache := cache.New(redisAddress)
+	// quota is per Battle.Net client, so instances sharing a client should share a limiter too
+	var limiter bnet.Limiter = bnet.NewLimiter(bnet.DefaultPerSecond, bnet.DefaultPerHour)
+	if sharedRateLimit {
+		limiter = cache.NewLimiter(redisCache, limiter, bnet.DefaultPerSecond, bnet.DefaultPerHour)
+	}
+	bnetClient := bnet.New(clientID, clientSecret, limiter)
 	dynamoStorage, _ := storage.New()
-	cacheService := service.New(redisCache, dynamoStorage, bnetClient)
 
-	http.HandleFunc("/profile", requestHandler(profileHandler, cacheService, http.StatusOK))
-	http.HandleFunc("/list", requestHandler(listHandler, cacheService, http.StatusOK))
-	http.HandleFunc("/list/add", requestHandler(addCharacterHandler, cacheService, http.StatusCreated))
-	http.HandleFunc("/list/delete", requestHandler(deleteCharacterHandler, cacheService, http.StatusNoContent))
+	if len(os.Args) > 1 && os.Args[1] == "migrate-character-ids" {
+		migrated, collided, err := dynamoStorage.MigrateCharacterIDs(context.Background(), service.NewRealmCatalog(bnetClient).Resolve)
+		if err != nil {
+			log.Fatalf("Migration failed after %d characters: %v", migrated, err)
+		}
+		log.Printf("[INFO] Migrated %d characters, %d collided with already saved ones and kept their old ids", migrated, collided)
+		return
+	}
+
+	var accounts service.AccountAuthorizer
+	switch {
+	case importCallbackURL != "" && stage == StageDev:
+		accounts = service.LocalAccounts{RedirectURL: importCallbackURL, Characters: devAccountCharacters()}
+	case importCallbackURL != "" && clientID != "":
+		accounts = service.BattleNetAccounts{Client: bnetClient, RedirectURL: importCallbackURL}
+	}
+	var rewriter service.AssetRewriter
+	if assetsURL != "" {
+		var store assets.Store = redisCache
+		if assetsDir != "" {
+			store, err = assets.NewDiskStore(assetsDir, assets.DefaultDiskLimit)
+			if err != nil {
+				log.Fatalf("Can't use assets directory %s: %v", assetsDir, err)
+			}
+		}
+		http.Handle(assets.Pattern, assets.NewProxy(store, assets.DefaultMaxSize))
+		rewriter = assets.Rewriter{BaseURL: assetsURL}
+	}
+	cacheService := service.New(redisCache, dynamoStorage, dynamoStorage, bnetClient, service.NewTooltipProviders(tooltipURL), rewriter, accounts)
+
+	if len(os.Args) > 1 && channelCommands[os.Args[1]] {
+		if err := runChannelCommand(context.Background(), os.Args[1:], cacheService, os.Stdin, os.Stdout); err != nil {
+			log.Fatalf("%s failed: %v", os.Args[1], err)
+		}
+		return
+	}
+
+	if accounts != nil {
+		http.HandleFunc("/import/callback", importCallbackHandler(cacheService))
+	}
+	registerV1(http.DefaultServeMux, cacheService)
+	registerV2(http.DefaultServeMux, cacheService)
+	http.HandleFunc("/openapi.json", openAPIHandler())
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprint(w, "Healthy")
+		states := bnetClient.BreakerStates()
+		for _, region := range model.Regions {
+			if state, ok := states[region]; ok {
+				fmt.Fprintf(w, "\nbnet %s: %s", region, state)
+			}
+		}
 		return
 	})
 	log.Println("Starting server")
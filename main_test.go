@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/salmondx/wow-twitch-extension/model"
+)
+
+func TestETag(t *testing.T) {
+	tag := etag([]byte(`{"Name":"Salmond"}`))
+	if tag != etag([]byte(`{"Name":"Salmond"}`)) {
+		t.Errorf("ETag is not stable")
+	}
+	if tag == etag([]byte(`{"Name":"Salmondx"}`)) {
+		t.Errorf("ETag doesn't depend on body")
+	}
+
+	var tests = []struct {
+		ifNoneMatch string
+		match       bool
+	}{
+		{"", false},
+		{tag, true},
+		{"W/" + tag, true},
+		{`"other", ` + tag, true},
+		{"*", true},
+		{`"other"`, false},
+	}
+	for _, tt := range tests {
+		if etagMatches(tt.ifNoneMatch, tag) != tt.match {
+			t.Errorf("If-None-Match %q should match: %v", tt.ifNoneMatch, tt.match)
+		}
+	}
+}
+
+func TestCacheControl(t *testing.T) {
+	var tests = []struct {
+		role         string
+		cacheControl string
+	}{
+		{"viewer", "public, max-age=60"},
+		{"broadcaster", "no-cache"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		writeResult(w, httptest.NewRequest(http.MethodGet, "/list", nil), []string{"Salmond"}, nil, http.StatusOK, callerMaxAge(tt.role, listMaxAge))
+		if w.Header().Get("Cache-Control") != tt.cacheControl || w.Header().Get("ETag") == "" {
+			t.Errorf("%s gets Cache-Control %q, ETag %q", tt.role, w.Header().Get("Cache-Control"), w.Header().Get("ETag"))
+		}
+	}
+}
+
+func TestAcceptedLanguages(t *testing.T) {
+	var tests = []struct {
+		header    string
+		languages []string
+	}{
+		{"", nil},
+		{"de-DE", []string{"de-DE"}},
+		{"fr;q=0.5, ru-RU, en;q=0.8", []string{"ru-RU", "en", "fr"}},
+		{"*, es;q=0, it;q=0.1", []string{"it"}},
+	}
+	for _, tt := range tests {
+		languages := acceptedLanguages(tt.header)
+		if fmt.Sprint(languages) != fmt.Sprint(tt.languages) {
+			t.Errorf("Accept-Language %q: %v, expected %v", tt.header, languages, tt.languages)
+		}
+	}
+}
+
+func TestHandleError(t *testing.T) {
+	var tests = []struct {
+		err        error
+		code       int
+		status     int
+		retryAfter time.Duration
+	}{
+		{model.CharacterNotFound{"not found"}, characterNotFound.Code, http.StatusNotFound, 0},
+		{fmt.Errorf("wrapped: %w", model.CharacterLimitError{"limit"}), characterLimit.Code, http.StatusConflict, 0},
+		{model.ValidationError{"missing realm"}, missingParameters.Code, http.StatusBadRequest, 0},
+		{model.UpstreamUnavailableError{S: "down", RetryAfter: 30 * time.Second}, upstreamUnavailable.Code, http.StatusServiceUnavailable, 30 * time.Second},
+		{fmt.Errorf("refresh: %w", model.RateLimitedError{S: "quota", RetryAfter: time.Second}), rateLimited.Code, http.StatusTooManyRequests, time.Second},
+		{fmt.Errorf("list: %w", context.DeadlineExceeded), requestTimeout.Code, http.StatusGatewayTimeout, 0},
+		{methodNotAllowed, invalidRequest.Code, http.StatusMethodNotAllowed, 0},
+		// v1 keeps its codes, v2 reports the error kind
+		{badRequest, invalidRequest.Code, http.StatusBadRequest, 0},
+		{wrongRole, invalidRequest.Code, http.StatusForbidden, 0},
+		{incompleteRequest, missingParameters.Code, http.StatusBadRequest, 0},
+		{notBroadcaster, forbidden.Code, http.StatusForbidden, 0},
+		{errors.New("unknown"), unknownError.Code, http.StatusInternalServerError, 0},
+	}
+	for _, tt := range tests {
+		errorMessage, status, retryAfter := handleError(tt.err)
+		if errorMessage.Code != tt.code || status != tt.status || retryAfter != tt.retryAfter {
+			t.Errorf("%v: got %d %d %v", tt.err, errorMessage.Code, status, retryAfter)
+		}
+	}
+}
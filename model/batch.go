@@ -0,0 +1,7 @@
+package model
+
+// BatchResult is an outcome of adding or deleting one character of a batch. Err is nil for succeeded operations
+type BatchResult struct {
+	CharacterRef
+	Err error
+}
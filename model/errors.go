@@ -1,5 +1,7 @@
 package model
 
+import "time"
+
 type CharacterLimitError struct {
 	S string
 }
@@ -16,6 +18,14 @@ func (e CharacterNotFound) Error() string {
 	return e.S
 }
 
+type GuildNotFound struct {
+	S string
+}
+
+func (e GuildNotFound) Error() string {
+	return e.S
+}
+
 type CharacterDuplicateError struct {
 	S string
 }
@@ -23,3 +33,68 @@ type CharacterDuplicateError struct {
 func (e CharacterDuplicateError) Error() string {
 	return e.S
 }
+
+// ValidationError is returned when request parameters are missing or malformed
+type ValidationError struct {
+	S string
+}
+
+func (e ValidationError) Error() string {
+	return e.S
+}
+
+// ForbiddenError is returned when caller is not allowed to perform an operation
+type ForbiddenError struct {
+	S string
+}
+
+func (e ForbiddenError) Error() string {
+	return e.S
+}
+
+// InvalidRegionError is returned for regions not supported by Battle.Net
+type InvalidRegionError struct {
+	S string
+}
+
+func (e InvalidRegionError) Error() string {
+	return e.S
+}
+
+// RealmNotFoundError is returned when realm doesn't exist in a region
+type RealmNotFoundError struct {
+	S string
+}
+
+func (e RealmNotFoundError) Error() string {
+	return e.S
+}
+
+// UpstreamUnavailableError is returned when Battle.Net fails or is considered down.
+// RetryAfter is a hint when to try again, zero if unknown
+type UpstreamUnavailableError struct {
+	S          string
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e UpstreamUnavailableError) Error() string {
+	if e.Err != nil {
+		return e.S + ". Reason: " + e.Err.Error()
+	}
+	return e.S
+}
+
+func (e UpstreamUnavailableError) Unwrap() error {
+	return e.Err
+}
+
+// RateLimitedError is returned when Battle.Net quota is exhausted
+type RateLimitedError struct {
+	S          string
+	RetryAfter time.Duration
+}
+
+func (e RateLimitedError) Error() string {
+	return e.S
+}
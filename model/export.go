@@ -0,0 +1,20 @@
+package model
+
+// ExportVersion is a version of the channel export format
+const ExportVersion = 1
+
+// ChannelExport is a portable copy of a channel. Characters are listed in the order of the channel list
+type ChannelExport struct {
+	// Version of the format. Exports without a version are read as the current version
+	Version    int
+	Characters []*CharacterInfo
+	// Settings are left unchanged on import when omitted
+	Settings *ChannelSettings `json:",omitempty"`
+}
+
+// ChannelImport is an outcome of importing a ChannelExport. Nothing is saved in a dry run
+type ChannelImport struct {
+	DryRun     bool
+	Characters []BatchResult
+	Settings   *ChannelSettings
+}
@@ -0,0 +1,32 @@
+package model
+
+// Guild is a guild profile with its roster
+type Guild struct {
+	Name      string
+	Realm     string
+	RealmSlug string
+	Region    Region
+	Locale    string
+	// Faction is a localized name, FactionKey is ALLIANCE or HORDE
+	Faction           string
+	FactionKey        string
+	FactionIcon       string
+	MemberCount       int
+	AchievementPoints int
+	// Members are ordered by rank, guild master first
+	Members []GuildMember
+}
+
+// GuildMember is a member of a guild roster. Rank 0 is the guild master, higher ranks are lower in hierarchy
+type GuildMember struct {
+	Name       string
+	RealmSlug  string
+	Level      int
+	Class      string
+	ClassKey   string
+	ClassID    int
+	ClassColor string
+	Race       string
+	RaceKey    string
+	Rank       int
+}
@@ -0,0 +1,27 @@
+package model
+
+import (
+	"strings"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+var fold = cases.Fold()
+
+// NormalizeName converts character name to a canonical form, so names differing only
+// in case or Unicode composition are the same character
+func NormalizeName(name string) string {
+	return fold.String(norm.NFC.String(strings.TrimSpace(name)))
+}
+
+// Slug converts realm or guild name to its Battle.Net slug, so "Twisting Nether", "twisting-nether"
+// and "Twisting-Nether" are equal. Apostrophes are dropped, like in "kelthuzad"
+func Slug(name string) string {
+	return strings.Replace(strings.Join(strings.Fields(NormalizeName(name)), "-"), "'", "", -1)
+}
+
+// CharacterID is a unique id of a character. Realm must be a realm slug
+func CharacterID(region Region, realmSlug, name string) string {
+	return string(region) + ":" + realmSlug + ":" + NormalizeName(name)
+}
@@ -0,0 +1,21 @@
+package model
+
+import "testing"
+
+func TestSlug(t *testing.T) {
+	var tests = []struct {
+		name string
+		slug string
+	}{
+		{"Twisting Nether", "twisting-nether"},
+		{"twisting-nether", "twisting-nether"},
+		{" Twisting  Nether ", "twisting-nether"},
+		{"Kel'Thuzad", "kelthuzad"},
+		{"Knights Who Say Ni", "knights-who-say-ni"},
+	}
+	for _, tt := range tests {
+		if slug := Slug(tt.name); slug != tt.slug {
+			t.Errorf("Slug(%q) = %q, expected %q", tt.name, slug, tt.slug)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package model
+
+// Statuses of an account import
+const (
+	// ImportPending waits for the broadcaster to authorize on Battle.Net
+	ImportPending = "pending"
+	// ImportReady lists characters of the account to pick from
+	ImportReady = "ready"
+)
+
+// AccountImport is an import of characters from a Battle.Net account. State identifies the import
+// in OAuth redirect, so it's known only to the broadcaster who started it
+type AccountImport struct {
+	State        string
+	StreamerID   string
+	Region       Region
+	Status       string
+	AuthorizeURL string `json:",omitempty"`
+	// Characters of the account, highest level first. Characters already added to the channel are skipped
+	Characters []*CharacterInfo
+}
@@ -2,13 +2,48 @@ package model
 
 // Item is a full description of a currently equipped item by type
 type Item struct {
-	Type           string
-	Name           string
-	ItemLvl        int
+	ID int
+	// Type is a stable slot key like MAIN_HAND, Slot is its localized name
+	Type    string
+	Slot    string
+	Name    string
+	ItemLvl int
+	// Quality is POOR, COMMON, UNCOMMON, RARE, EPIC, LEGENDARY, ARTIFACT or HEIRLOOM
+	Quality        string
+	Armor          int `json:",omitempty"`
+	Stats          []ItemStat
+	BonusIDs       []int
+	Gems           []int    `json:",omitempty"`
+	Enchantment    int      `json:",omitempty"`
+	Set            *ItemSet `json:",omitempty"`
 	IconURL        string
 	DescriptionURL string `json:",omitempty"`
 }
 
+// ItemStat is a stat of an item. Kind is primary, secondary or tertiary
+type ItemStat struct {
+	Type   string
+	Name   string
+	Kind   string
+	Amount int
+}
+
+// ItemSet describes a set the item belongs to, like a raid tier set
+type ItemSet struct {
+	ID   int
+	Name string
+	// EquippedItems are ids of equipped items of the set
+	EquippedItems []int
+	Bonuses       []SetBonus
+}
+
+// SetBonus is an effect of an item set, active once Threshold items are equipped
+type SetBonus struct {
+	Threshold   int
+	Description string
+	Active      bool
+}
+
 type Spell struct {
 	ID             int
 	Name           string
@@ -17,34 +52,96 @@ type Spell struct {
 	DescriptionURL string
 }
 
+// Talent is a talent of the old layout with one talent per tier
 type Talent struct {
 	Tier  int
 	Spell Spell
 }
 
+// TalentNode is a node of a modern talent tree. Choice nodes offer several talents, Spell is the chosen one
+type TalentNode struct {
+	NodeID   int
+	TalentID int
+	Rank     int
+	MaxRank  int
+	Choice   bool
+	Spell    Spell
+}
+
+// TalentTree lists nodes with points spent
+type TalentTree struct {
+	ID    int
+	Name  string
+	Nodes []TalentNode
+}
+
 type Spec struct {
+	ID       int
 	Selected bool
-	Name     string
-	IconURL  string
-	Order    int
-	Talents  []Talent
+	// Key is a stable spec key like FROST, unique within a class
+	Key     string
+	Name    string
+	IconURL string
+	Order   int
+	Talents []Talent
+	// Modern talent trees of the active loadout. LoadoutCode is its in-game export string
+	ClassTree   *TalentTree `json:",omitempty"`
+	SpecTree    *TalentTree `json:",omitempty"`
+	HeroTree    *TalentTree `json:",omitempty"`
+	LoadoutCode string      `json:",omitempty"`
 }
 
+// ArenaRating is a rating in a PvP bracket. Type is 2v2, 3v3, RBG, SHUFFLE or BLITZ.
+// Solo Shuffle and Blitz are rated per spec, so a character has one of them for every spec played
 type ArenaRating struct {
-	Type         string
+	Type string
+	// Bracket is a unique bracket key, like shuffle-warrior-arms
+	Bracket      string `json:",omitempty"`
+	SpecID       int    `json:",omitempty"`
+	Spec         string `json:",omitempty"`
 	Rating       int
+	SeasonID     int    `json:",omitempty"`
+	SeasonHigh   int    `json:",omitempty"`
+	Tier         string `json:",omitempty"`
 	SeasonPlayed int
 	SeasonWon    int
 	SeasonLost   int
+	WeeklyPlayed int
+	WeeklyWon    int
+	WeeklyLost   int
 }
 
 // Character is a full description of a WoW character with items
 type Character struct {
-	Name        string
-	Realm       string
-	Class       string
-	Region      string
+	Name      string
+	Realm     string
+	RealmSlug string
+	Class     string
+	// ClassKey is a stable class key like DEATH_KNIGHT, Class is its localized name
+	ClassKey   string
+	ClassID    int
+	ClassColor string
+	// SpecID and Role describe the active spec. Role is tank, healer or damage
+	SpecID  int
+	Role    string
+	Race    string
+	RaceKey string
+	// Faction is a localized name, FactionKey is ALLIANCE, HORDE or NEUTRAL
+	Faction     string
+	FactionKey  string
+	FactionIcon string
+	// Gender is MALE or FEMALE
+	Gender string
+	Level  int
+	// Title is the name with the selected title, like "Salmond the Insane"
+	Title             string
+	AchievementPoints int
+	Region            Region
+	Locale            string
+	// CharIcon is the avatar thumbnail. InsetURL is a bust image and RenderURL is a full render with transmog
 	CharIcon    string
+	InsetURL    string
+	RenderURL   string
 	ItemLvl     int
 	Guild       string
 	Items       []Item
@@ -54,11 +151,63 @@ type Character struct {
 
 // CharacterInfo is a short description of a WoW character, without items
 type CharacterInfo struct {
-	Name     string
-	Realm    string
-	Region   string
-	Class    string
-	CharIcon string
-	Guild    string
-	ItemLvl  int
+	Name              string
+	Realm             string
+	RealmSlug         string
+	Region            Region
+	Class             string
+	ClassKey          string
+	ClassID           int
+	ClassColor        string
+	SpecID            int
+	Role              string
+	Race              string
+	RaceKey           string
+	Faction           string
+	FactionKey        string
+	FactionIcon       string
+	Gender            string
+	Level             int
+	Title             string
+	AchievementPoints int
+	CharIcon          string
+	Guild             string
+	ItemLvl           int
+	// Order is a position in the channel list. Characters saved before it was introduced have zero and are listed first
+	Order int64
+}
+
+// CharacterRef identifies a character by region, realm and name. Realm is a realm name or slug
+type CharacterRef struct {
+	Region Region
+	Realm  string
+	Name   string
+}
+
+// Info returns short description of the character
+func (c *Character) Info() *CharacterInfo {
+	return &CharacterInfo{
+		Name:              c.Name,
+		Realm:             c.Realm,
+		RealmSlug:         c.RealmSlug,
+		Region:            c.Region,
+		Class:             c.Class,
+		ClassKey:          c.ClassKey,
+		ClassID:           c.ClassID,
+		ClassColor:        c.ClassColor,
+		SpecID:            c.SpecID,
+		Role:              c.Role,
+		Race:              c.Race,
+		RaceKey:           c.RaceKey,
+		Faction:           c.Faction,
+		FactionKey:        c.FactionKey,
+		FactionIcon:       c.FactionIcon,
+		Gender:            c.Gender,
+		Level:             c.Level,
+		Title:             c.Title,
+		AchievementPoints: c.AchievementPoints,
+		CharIcon:          c.CharIcon,
+		Guild:             c.Guild,
+		ItemLvl:           c.ItemLvl,
+	}
 }
@@ -0,0 +1,39 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Region is a Battle.Net region
+type Region string
+
+const (
+	US Region = "us"
+	EU Region = "eu"
+	KR Region = "kr"
+	TW Region = "tw"
+	CN Region = "cn"
+)
+
+// Regions are all supported regions
+var Regions = []Region{US, EU, KR, TW, CN}
+
+// ParseRegion validates region in any case. InvalidRegionError is returned for unsupported regions
+func ParseRegion(region string) (Region, error) {
+	parsed := Region(strings.ToLower(strings.TrimSpace(region)))
+	if !parsed.Valid() {
+		return "", InvalidRegionError{fmt.Sprintf("Region %q is not supported. Use one of us, eu, kr, tw or cn", region)}
+	}
+	return parsed, nil
+}
+
+// Valid reports whether region is supported
+func (r Region) Valid() bool {
+	for _, region := range Regions {
+		if r == region {
+			return true
+		}
+	}
+	return false
+}
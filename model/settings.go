@@ -0,0 +1,7 @@
+package model
+
+// ChannelSettings are preferences of a broadcaster, shared by all viewers of the channel
+type ChannelSettings struct {
+	// TooltipProvider is a name of the site item and spell links lead to, like wowhead
+	TooltipProvider string
+}
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/salmondx/wow-twitch-extension/model"
+)
+
+// apiParameter is a path or query parameter of an operation
+type apiParameter struct {
+	Name        string
+	In          string
+	Description string
+	Optional    bool
+}
+
+// apiOperation describes one method of an API route.
+// Body and Response are sample values, their schemas are generated from types
+type apiOperation struct {
+	Summary     string
+	Parameters  []apiParameter
+	Body        interface{}
+	Response    interface{}
+	SuccessCode int
+	Cachable    bool
+}
+
+var (
+	realmParameter  = apiParameter{"realm", "query", "Realm name", false}
+	nameParameter   = apiParameter{"name", "query", "Character name", false}
+	regionParameter = apiParameter{"region", "query", "Battle.Net region: us, eu, kr, tw or cn", false}
+	localeParameter = apiParameter{"locale", "query", "Locale like de_DE, overrides Accept-Language. Unsupported locales fall back to region default", true}
+
+	channelPathParameter    = apiParameter{"id", "path", "Twitch channel id, must match the token", false}
+	characterPathParameters = []apiParameter{
+		channelPathParameter,
+		{"region", "path", "Battle.Net region: us, eu, kr, tw or cn", false},
+		{"realm", "path", "Realm name", false},
+		{"name", "path", "Character name", false},
+	}
+	importPathParameter = apiParameter{"state", "path", "State of account import", false}
+	guildPathParameters = []apiParameter{
+		channelPathParameter,
+		{"region", "path", "Battle.Net region: us, eu, kr, tw or cn", false},
+		{"realm", "path", "Realm name", false},
+		{"name", "path", "Guild name", false},
+		localeParameter,
+	}
+	profilePathParameters = append(characterPathParameters[:len(characterPathParameters):len(characterPathParameters)], localeParameter)
+)
+
+// errorCodes are all ErrorMessage codes returned by the API
+var errorCodes = []ErrorMessage{
+	characterNotFound,
+	characterLimit,
+	unknownError,
+	missingParameters,
+	characterDuplicate,
+	invalidRequest,
+	upstreamUnavailable,
+	rateLimited,
+	invalidRegion,
+	realmNotFound,
+	forbidden,
+	requestTimeout,
+	guildNotFound,
+}
+
+// apiPaths describes every registered route. Keep it in sync with handlers, openapi_test.go checks they don't drift
+var apiPaths = map[string]map[string]apiOperation{
+	"/profile": {
+		http.MethodGet: {
+			Summary:     "Full character profile",
+			Parameters:  []apiParameter{realmParameter, nameParameter, regionParameter, localeParameter},
+			Response:    model.Character{},
+			SuccessCode: http.StatusOK,
+			Cachable:    true,
+		},
+	},
+	"/list": {
+		http.MethodGet: {
+			Summary:     "Characters of the channel",
+			Response:    []*model.CharacterInfo{},
+			SuccessCode: http.StatusOK,
+			Cachable:    true,
+		},
+	},
+	"/list/add": {
+		http.MethodPost: {
+			Summary:     "Add character to the channel. Broadcaster only",
+			Parameters:  []apiParameter{realmParameter, nameParameter, regionParameter},
+			SuccessCode: http.StatusCreated,
+		},
+	},
+	"/list/delete": {
+		http.MethodDelete: {
+			Summary:     "Delete character from the channel. Broadcaster only",
+			Parameters:  []apiParameter{realmParameter, nameParameter, regionParameter},
+			SuccessCode: http.StatusNoContent,
+		},
+	},
+	"/guild": {
+		http.MethodGet: {
+			Summary:     "Guild profile with roster ordered by rank",
+			Parameters:  []apiParameter{realmParameter, {"name", "query", "Guild name", false}, regionParameter, localeParameter},
+			Response:    model.Guild{},
+			SuccessCode: http.StatusOK,
+			Cachable:    true,
+		},
+	},
+	"/v2/channels/{id}/guilds/{region}/{realm}/{name}": {
+		http.MethodGet: {
+			Summary:     "Guild profile with roster ordered by rank",
+			Parameters:  guildPathParameters,
+			Response:    model.Guild{},
+			SuccessCode: http.StatusOK,
+			Cachable:    true,
+		},
+	},
+	"/v2/channels/{id}/characters": {
+		http.MethodGet: {
+			Summary:     "Characters of the channel",
+			Parameters:  []apiParameter{channelPathParameter},
+			Response:    []*model.CharacterInfo{},
+			SuccessCode: http.StatusOK,
+			Cachable:    true,
+		},
+		http.MethodPost: {
+			Summary:     "Add character to the channel. Broadcaster only",
+			Parameters:  []apiParameter{channelPathParameter},
+			Body:        CharacterRequest{},
+			SuccessCode: http.StatusCreated,
+		},
+	},
+	"/v2/channels/{id}/characters/{region}/{realm}/{name}": {
+		http.MethodDelete: {
+			Summary:     "Delete character from the channel. Broadcaster only",
+			Parameters:  characterPathParameters,
+			SuccessCode: http.StatusNoContent,
+		},
+	},
+	"/v2/channels/{id}/characters/batch": {
+		http.MethodPost: {
+			Summary: "Delete and add characters with single writes, deletes first. Broadcaster only. " +
+				"Every character gets its own Status and Error. Characters which don't fit into the limit are not added at all",
+			Parameters:  []apiParameter{channelPathParameter},
+			Body:        BatchRequest{},
+			Response:    BatchResponse{},
+			SuccessCode: http.StatusOK,
+		},
+	},
+	"/v2/channels/{id}/export": {
+		http.MethodGet: {
+			Summary:     "Stored characters of the channel in list order with channel settings. Broadcaster only",
+			Parameters:  []apiParameter{channelPathParameter},
+			Response:    model.ChannelExport{},
+			SuccessCode: http.StatusOK,
+		},
+	},
+	"/v2/channels/{id}/channel-import": {
+		http.MethodPost: {
+			Summary: "Add characters of a channel export which are missing in the channel and replace settings. Broadcaster only. " +
+				"Characters are validated against Battle.Net and get their own Status and Error",
+			Parameters:  []apiParameter{channelPathParameter, {"dryRun", "query", "Report results without saving anything when true", true}},
+			Body:        model.ChannelExport{},
+			Response:    ChannelImportResponse{},
+			SuccessCode: http.StatusOK,
+		},
+	},
+	"/v2/channels/{id}/imports": {
+		http.MethodPost: {
+			Summary:     "Start import of characters from a Battle.Net account. Broadcaster only. Broadcaster authorizes on AuthorizeURL",
+			Parameters:  []apiParameter{channelPathParameter},
+			Body:        ImportRequest{},
+			Response:    model.AccountImport{},
+			SuccessCode: http.StatusCreated,
+		},
+	},
+	"/v2/channels/{id}/imports/{state}": {
+		http.MethodGet: {
+			Summary:     "Account import. Characters of the account are listed once its status is ready. Broadcaster only",
+			Parameters:  []apiParameter{channelPathParameter, importPathParameter},
+			Response:    model.AccountImport{},
+			SuccessCode: http.StatusOK,
+		},
+	},
+	"/v2/channels/{id}/imports/{state}/characters": {
+		http.MethodPost: {
+			Summary:     "Add characters picked from account import with a single write. Already added characters are skipped. Broadcaster only",
+			Parameters:  []apiParameter{channelPathParameter, importPathParameter},
+			Body:        ImportCharactersRequest{},
+			Response:    []*model.CharacterInfo{},
+			SuccessCode: http.StatusCreated,
+		},
+	},
+	"/v2/channels/{id}/settings": {
+		http.MethodGet: {
+			Summary:     "Channel settings",
+			Parameters:  []apiParameter{channelPathParameter},
+			Response:    model.ChannelSettings{},
+			SuccessCode: http.StatusOK,
+		},
+		http.MethodPut: {
+			Summary:     "Replace channel settings. Broadcaster only. TooltipProvider is wowhead, wowhead-classic or self-hosted, if configured",
+			Parameters:  []apiParameter{channelPathParameter},
+			Body:        model.ChannelSettings{},
+			SuccessCode: http.StatusNoContent,
+		},
+	},
+	"/v2/channels/{id}/characters/{region}/{realm}/{name}/profile": {
+		http.MethodGet: {
+			Summary:     "Full character profile",
+			Parameters:  profilePathParameters,
+			Response:    model.Character{},
+			SuccessCode: http.StatusOK,
+			Cachable:    true,
+		},
+	},
+}
+
+// openAPIHandler serves OpenAPI 3 specification. It's generated once, on start
+func openAPIHandler() http.HandlerFunc {
+	spec, err := json.Marshal(openAPISpec())
+	if err != nil {
+		log.Fatalf("Can't generate OpenAPI specification: %v", err)
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Access-Control-Allow-Origin", "*")
+		w.Header().Add("Content-Type", "application/json")
+		w.Write(spec)
+	}
+}
+
+func openAPISpec() map[string]interface{} {
+	schemas := make(map[string]interface{})
+	paths := make(map[string]interface{})
+	for path, operations := range apiPaths {
+		item := make(map[string]interface{})
+		for method, operation := range operations {
+			item[strings.ToLower(method)] = openAPIOperation(operation, schemas)
+		}
+		paths[path] = item
+	}
+	schemas["ErrorMessage"] = errorSchema()
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "WoW Armory Twitch extension",
+			"version": "2",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"twitch": map[string]interface{}{
+					"type":        "apiKey",
+					"in":          "header",
+					"name":        "Authorization",
+					"description": "Twitch extension JWT",
+				},
+			},
+		},
+		"security": []interface{}{map[string]interface{}{"twitch": []string{}}},
+	}
+}
+
+func openAPIOperation(operation apiOperation, schemas map[string]interface{}) map[string]interface{} {
+	parameters := make([]interface{}, 0, len(operation.Parameters))
+	for _, parameter := range operation.Parameters {
+		parameters = append(parameters, map[string]interface{}{
+			"name":        parameter.Name,
+			"in":          parameter.In,
+			"description": parameter.Description,
+			"required":    !parameter.Optional,
+			"schema":      map[string]interface{}{"type": "string"},
+		})
+	}
+
+	success := map[string]interface{}{"description": http.StatusText(operation.SuccessCode)}
+	if operation.Response != nil {
+		success["content"] = jsonContent(schemaOf(reflect.TypeOf(operation.Response), schemas))
+	}
+	responses := map[string]interface{}{
+		strconv.Itoa(operation.SuccessCode): success,
+		"401":                               map[string]interface{}{"description": "Token is missing or invalid"},
+		"default":                           map[string]interface{}{"description": "Error", "content": jsonContent(map[string]interface{}{"$ref": "#/components/schemas/ErrorMessage"})},
+	}
+	if operation.Cachable {
+		responses["304"] = map[string]interface{}{"description": "Not modified since ETag from If-None-Match"}
+	}
+
+	result := map[string]interface{}{
+		"summary":    operation.Summary,
+		"parameters": parameters,
+		"responses":  responses,
+	}
+	if operation.Body != nil {
+		result["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  jsonContent(schemaOf(reflect.TypeOf(operation.Body), schemas)),
+		}
+	}
+	return result
+}
+
+func jsonContent(schema interface{}) map[string]interface{} {
+	return map[string]interface{}{"application/json": map[string]interface{}{"schema": schema}}
+}
+
+func errorSchema() map[string]interface{} {
+	codes := make([]interface{}, len(errorCodes))
+	descriptions := make([]string, len(errorCodes))
+	for i, e := range errorCodes {
+		codes[i] = e.Code
+		descriptions[i] = strconv.Itoa(e.Code) + ": " + e.Reason
+	}
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"Code":   map[string]interface{}{"type": "integer", "enum": codes, "description": strings.Join(descriptions, "; ")},
+			"Reason": map[string]interface{}{"type": "string"},
+		},
+	}
+}
+
+// schemaOf generates JSON schema of a type. Named structs are added to schemas and referenced
+func schemaOf(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaOf(t.Elem(), schemas)
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaOf(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaOf(t.Elem(), schemas)}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Struct:
+		ref := map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+		if _, ok := schemas[t.Name()]; ok {
+			return ref
+		}
+		// placeholder stops recursion on self referencing types
+		schemas[t.Name()] = nil
+		properties := make(map[string]interface{})
+		structProperties(t, properties, schemas)
+		schemas[t.Name()] = map[string]interface{}{"type": "object", "properties": properties}
+		return ref
+	}
+	return map[string]interface{}{}
+}
+
+// structProperties adds JSON fields of a struct to properties, following encoding/json rules
+func structProperties(t reflect.Type, properties map[string]interface{}, schemas map[string]interface{}) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			structProperties(embedded, properties, schemas)
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = schemaOf(field.Type, schemas)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/salmondx/wow-twitch-extension/model"
+)
+
+var pathValues = strings.NewReplacer("{id}", "testing_streamer", "{region}", "eu", "{realm}", "Soulflayer", "{name}", "Salmond")
+
+// TestOpenAPIMatchesHandlers calls every registered route with every method and
+// checks that specification documents exactly the methods that are served
+func TestOpenAPIMatchesHandlers(t *testing.T) {
+	stage = StageDev
+	mux := http.NewServeMux()
+	patterns := append(registerV1(mux, &stubService{}), registerV2(mux, &stubService{})...)
+
+	if len(patterns) != len(apiPaths) {
+		t.Errorf("%d routes registered, %d documented", len(patterns), len(apiPaths))
+	}
+	for _, pattern := range patterns {
+		operations, ok := apiPaths[pattern]
+		if !ok {
+			t.Errorf("%s is not documented", pattern)
+			continue
+		}
+		for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodDelete, http.MethodPut} {
+			url := pathValues.Replace(pattern) + "?realm=Soulflayer&name=Salmond&region=eu"
+			r := httptest.NewRequest(method, url, strings.NewReader(`{"Region":"eu","Realm":"Soulflayer","Name":"Salmond"}`))
+			r.Header.Set("Authorization", "token")
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+
+			operation, documented := operations[method]
+			if documented && w.Code != operation.SuccessCode {
+				t.Errorf("%s %s: status %d, documented %d", method, pattern, w.Code, operation.SuccessCode)
+			}
+			if !documented && w.Code != http.StatusMethodNotAllowed {
+				t.Errorf("%s %s: served with status %d, but not documented", method, pattern, w.Code)
+			}
+		}
+	}
+}
+
+func TestOpenAPIErrorCodes(t *testing.T) {
+	documented := make(map[int]bool)
+	for _, e := range errorCodes {
+		documented[e.Code] = true
+	}
+	for _, err := range []error{
+		model.CharacterNotFound{},
+		model.GuildNotFound{},
+		model.CharacterLimitError{},
+		model.CharacterDuplicateError{},
+		model.ValidationError{},
+		model.ForbiddenError{},
+		model.InvalidRegionError{},
+		model.RealmNotFoundError{},
+		model.UpstreamUnavailableError{},
+		model.RateLimitedError{},
+		methodNotAllowed,
+		context.DeadlineExceeded,
+		errors.New("unknown"),
+	} {
+		errorMessage, _, _ := handleError(err)
+		if !documented[errorMessage.Code] {
+			t.Errorf("Code %d of %T is not documented", errorMessage.Code, err)
+		}
+	}
+}
+
+func TestOpenAPISchemas(t *testing.T) {
+	data, err := json.Marshal(openAPISpec())
+	if err != nil {
+		t.Fatalf("Can't serialize specification: %v", err)
+	}
+	var spec struct {
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]interface{}
+			}
+		}
+	}
+	json.Unmarshal(data, &spec)
+
+	for name, fields := range map[string][]string{
+		"Character":     {"Name", "Realm", "Items", "Specs", "ArenaRating"},
+		"CharacterInfo": {"Name", "Realm", "Region", "ItemLvl"},
+		"Item":          {"Type", "DescriptionURL"},
+		"ErrorMessage":  {"Code", "Reason"},
+	} {
+		schema, ok := spec.Components.Schemas[name]
+		if !ok {
+			t.Errorf("Schema %s not found", name)
+			continue
+		}
+		for _, field := range fields {
+			if _, ok := schema.Properties[field]; !ok {
+				t.Errorf("%s.%s not found", name, field)
+			}
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"github.com/salmondx/wow-twitch-extension/model"
+)
+
+// AssetRewriter rewrites image URLs served to viewers, like to URLs of an image proxy
+type AssetRewriter interface {
+	Rewrite(url string) string
+}
+
+// rewriteProfileAssets rewrites icons and renders of a profile. Nil rewriter keeps them as is
+func rewriteProfileAssets(rewriter AssetRewriter, profile *model.Character) {
+	if rewriter == nil {
+		return
+	}
+	profile.CharIcon = rewriter.Rewrite(profile.CharIcon)
+	profile.InsetURL = rewriter.Rewrite(profile.InsetURL)
+	profile.RenderURL = rewriter.Rewrite(profile.RenderURL)
+	profile.FactionIcon = rewriter.Rewrite(profile.FactionIcon)
+	for i := range profile.Items {
+		profile.Items[i].IconURL = rewriter.Rewrite(profile.Items[i].IconURL)
+	}
+	for i := range profile.Specs {
+		spec := &profile.Specs[i]
+		spec.IconURL = rewriter.Rewrite(spec.IconURL)
+		for j := range spec.Talents {
+			spec.Talents[j].Spell.IconURL = rewriter.Rewrite(spec.Talents[j].Spell.IconURL)
+		}
+	}
+}
+
+// rewriteInfoAssets rewrites icons of characters list. Nil rewriter keeps them as is
+func rewriteInfoAssets(rewriter AssetRewriter, characters []*model.CharacterInfo) {
+	if rewriter == nil {
+		return
+	}
+	for _, character := range characters {
+		character.CharIcon = rewriter.Rewrite(character.CharIcon)
+		character.FactionIcon = rewriter.Rewrite(character.FactionIcon)
+	}
+}
@@ -0,0 +1,211 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/salmondx/wow-twitch-extension/bnet"
+	"github.com/salmondx/wow-twitch-extension/model"
+	"github.com/salmondx/wow-twitch-extension/storage"
+)
+
+// maxBatchSize limits characters of a single batch. Channel can't have more characters anyway
+const maxBatchSize = storage.CharacterLimit
+
+// batchProfile is a profile fetched for a character of a batch. Only cached profiles are described
+type batchProfile struct {
+	profile    *model.Character
+	validators bnet.Validators
+	cached     bool
+	err        error
+}
+
+// AddMany adds characters with a single storage write. Result of every character is reported separately,
+// characters which don't fit into the limit are not added at all
+func (s *CachableCharacterService) AddMany(ctx context.Context, streamerID string, characters []model.CharacterRef) ([]model.BatchResult, error) {
+	return s.addMany(ctx, streamerID, characters, nil, false)
+}
+
+// addMany validates characters against Battle.Net and adds them unless it's a dry run.
+// orders are positions of characters in the list, new characters are put at the end when orders are nil
+func (s *CachableCharacterService) addMany(ctx context.Context, streamerID string, characters []model.CharacterRef, orders []int64, dryRun bool) ([]model.BatchResult, error) {
+	if err := validateBatch(streamerID, characters); err != nil {
+		return nil, err
+	}
+	existing, err := s.List(ctx, streamerID)
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[string]bool, len(existing))
+	for _, character := range existing {
+		ids[s.characterID(ctx, character)] = true
+	}
+
+	results := make([]model.BatchResult, len(characters))
+	fetched := s.fetchProfiles(ctx, streamerID, characters)
+	added := make([]int, 0, len(characters))
+	selected := make([]*model.CharacterInfo, 0, len(characters))
+	for i, character := range characters {
+		results[i].CharacterRef = character
+		if fetched[i].err != nil {
+			results[i].Err = fetched[i].err
+			continue
+		}
+		profile := fetched[i].profile
+		id := model.CharacterID(profile.Region, profile.RealmSlug, profile.Name)
+		if ids[id] {
+			results[i].Err = model.CharacterDuplicateError{fmt.Sprintf("Character with name %s on realm %s already exists", profile.Name, profile.Realm)}
+			continue
+		}
+		ids[id] = true
+		info := profile.Info()
+		if orders != nil {
+			info.Order = orders[i]
+		}
+		added = append(added, i)
+		selected = append(selected, info)
+	}
+
+	if dryRun {
+		err = nil
+		if len(existing)+len(selected) > storage.CharacterLimit {
+			err = model.CharacterLimitError{fmt.Sprintf("Can't add %d characters for %s. Limit is %d.", len(selected), streamerID, storage.CharacterLimit)}
+		}
+	} else {
+		var failed []error
+		failed, err = s.storage.AddMany(ctx, streamerID, selected)
+		// characters added by a concurrent request since the list was read
+		for k, failure := range failed {
+			if failure != nil {
+				results[added[k]].Err = failure
+			}
+		}
+	}
+	var limit model.CharacterLimitError
+	if errors.As(err, &limit) {
+		for _, i := range added {
+			if results[i].Err == nil {
+				results[i].Err = err
+			}
+		}
+		return results, nil
+	}
+	if err != nil || dryRun {
+		return results, err
+	}
+	for _, i := range added {
+		if results[i].Err != nil {
+			continue
+		}
+		if fetched[i].cached {
+			err = s.cache.AddProfile(ctx, streamerID, fetched[i].profile, fetched[i].validators)
+		} else {
+			// profile is described when a viewer opens it
+			err = s.cache.AddPartialProfile(ctx, streamerID, fetched[i].profile)
+		}
+		if err != nil {
+			log.Printf("[ERROR] Can not save profile in cache %s. %v", streamerID, err)
+		}
+	}
+	if len(added) > 0 {
+		s.clearList(ctx, streamerID)
+	}
+	return results, nil
+}
+
+// DeleteMany deletes characters with a single storage write. Result of every character is reported separately
+func (s *CachableCharacterService) DeleteMany(ctx context.Context, streamerID string, characters []model.CharacterRef) ([]model.BatchResult, error) {
+	if err := validateBatch(streamerID, characters); err != nil {
+		return nil, err
+	}
+
+	results := make([]model.BatchResult, len(characters))
+	deleted := make([]int, 0, len(characters))
+	selected := make([]model.CharacterRef, 0, len(characters))
+	for i, character := range characters {
+		results[i].CharacterRef = character
+		if err := validateCharacter(character); err != nil {
+			results[i].Err = err
+			continue
+		}
+		realm, err := s.realms.Resolve(ctx, character.Region, character.Realm)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		deleted = append(deleted, i)
+		selected = append(selected, model.CharacterRef{Region: character.Region, Realm: realm, Name: character.Name})
+	}
+
+	failed, err := s.storage.DeleteMany(ctx, streamerID, selected)
+	if err != nil {
+		return nil, err
+	}
+	for k, failure := range failed {
+		results[deleted[k]].Err = failure
+	}
+	if len(selected) > 0 {
+		s.clearList(ctx, streamerID)
+	}
+	return results, nil
+}
+
+// fetchProfiles retrieves profiles of all characters simultaneously. Profiles cached for the streamer are revalidated
+func (s *CachableCharacterService) fetchProfiles(ctx context.Context, streamerID string, characters []model.CharacterRef) []batchProfile {
+	fetched := make([]batchProfile, len(characters))
+	var wg sync.WaitGroup
+	for i, character := range characters {
+		if err := validateCharacter(character); err != nil {
+			fetched[i].err = err
+			continue
+		}
+		wg.Add(1)
+		go func(i int, character model.CharacterRef) {
+			defer wg.Done()
+			realm, err := s.realms.Resolve(ctx, character.Region, character.Realm)
+			if err != nil {
+				fetched[i].err = err
+				return
+			}
+			profile, validators, cached, err := s.revalidateProfile(ctx, streamerID, character.Region, realm, character.Name)
+			if err != nil {
+				fetched[i].err = err
+				return
+			}
+			fetched[i] = batchProfile{profile: profile, validators: validators, cached: cached}
+		}(i, character)
+	}
+	wg.Wait()
+	return fetched
+}
+
+func (s *CachableCharacterService) clearList(ctx context.Context, streamerID string) {
+	err := s.cache.ClearList(ctx, streamerID)
+	if err != nil {
+		log.Printf("[ERROR] Can not clear characters in cache %s. %v", streamerID, err)
+	}
+}
+
+func validateBatch(streamerID string, characters []model.CharacterRef) error {
+	if streamerID == "" {
+		return model.ValidationError{"StreamerID can not be empty"}
+	}
+	if len(characters) > maxBatchSize {
+		return model.ValidationError{fmt.Sprintf("Batch can't have more than %d characters", maxBatchSize)}
+	}
+	return nil
+}
+
+// validateCharacter checks a character of a batch, so a malformed one fails alone
+func validateCharacter(character model.CharacterRef) error {
+	if character.Realm == "" || character.Name == "" {
+		return model.ValidationError{"Realm or name can not be empty"}
+	}
+	if !character.Region.Valid() {
+		return model.InvalidRegionError{fmt.Sprintf("Region %q is not supported. Use one of us, eu, kr, tw or cn", character.Region)}
+	}
+	return nil
+}
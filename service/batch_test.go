@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/salmondx/wow-twitch-extension/cache"
+	"github.com/salmondx/wow-twitch-extension/model"
+	"github.com/salmondx/wow-twitch-extension/storage"
+)
+
+type deletingStorage struct {
+	storage.CharacterRepository
+	deleted []model.CharacterRef
+}
+
+func (s *deletingStorage) DeleteMany(ctx context.Context, streamerID string, characters []model.CharacterRef) ([]error, error) {
+	s.deleted = characters
+	failed := make([]error, len(characters))
+	for i, character := range characters {
+		if character.Name == "Missing" {
+			failed[i] = model.CharacterNotFound{"Character is not added"}
+		}
+	}
+	return failed, nil
+}
+
+type clearingCache struct {
+	cache.Cache
+	cleared bool
+}
+
+func (c *clearingCache) ClearList(ctx context.Context, streamerID string) error {
+	c.cleared = true
+	return nil
+}
+
+func TestDeleteMany(t *testing.T) {
+	store := &deletingStorage{}
+	listCache := &clearingCache{}
+	s := &CachableCharacterService{storage: store, cache: listCache, realms: NewRealmCatalog(nil)}
+
+	results, err := s.DeleteMany(context.Background(), "streamer", []model.CharacterRef{
+		{Region: model.EU, Realm: "Свежеватель Душ", Name: "Salmond"},
+		{Region: model.EU, Realm: "Soulflayer", Name: "Missing"},
+		{Region: "sea", Realm: "Soulflayer", Name: "Salmond"},
+		{Region: model.EU, Realm: "", Name: "Salmond"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(store.deleted) != 2 || store.deleted[0].Realm != "soulflayer" {
+		t.Errorf("Wrong characters are deleted: %v", store.deleted)
+	}
+	if results[0].Err != nil || results[0].Realm != "Свежеватель Душ" {
+		t.Errorf("Deleted character is not reported as requested: %v", results[0])
+	}
+	if _, ok := results[1].Err.(model.CharacterNotFound); !ok {
+		t.Errorf("Missing character is not reported: %v", results[1].Err)
+	}
+	if _, ok := results[2].Err.(model.InvalidRegionError); !ok {
+		t.Errorf("Invalid region is not reported: %v", results[2].Err)
+	}
+	if _, ok := results[3].Err.(model.ValidationError); !ok {
+		t.Errorf("Missing realm is not reported: %v", results[3].Err)
+	}
+	if !listCache.cleared {
+		t.Errorf("Cached list is not cleared")
+	}
+}
@@ -1,38 +1,63 @@
 package service
 
 import (
-	"bytes"
 	"fmt"
 	"reflect"
 	"strings"
 
 	"github.com/salmondx/wow-twitch-extension/bnet"
+	"github.com/salmondx/wow-twitch-extension/gamedata"
 	"github.com/salmondx/wow-twitch-extension/model"
 )
 
 const iconPlaceholderURL = "https://render-%s.worldofwarcraft.com/icons/36/%s.jpg"
 const charIconPlaceholderURL = "https://render-%s.worldofwarcraft.com/character/%s"
 
-const wowheadURL = "item=%d"
-const wowheadSpellURL = "spell=%d"
-
 // Convert converts profile from Battle.Net API to a required object
 func Convert(bnetProfile *bnet.CharacterProfile) *model.Character {
 	extensionProfile := model.Character{}
 	extensionProfile.Name = bnetProfile.Name
 	extensionProfile.Realm = bnetProfile.Realm
 	extensionProfile.Region = bnetProfile.Region
+	extensionProfile.Locale = bnetProfile.Locale
 	extensionProfile.Guild = bnetProfile.Guild.Name
 	extensionProfile.ItemLvl = bnetProfile.Items.AverageItemLevelEquipped
-	extensionProfile.Class = classByIndex(bnetProfile.Class)
+	if class, ok := gamedata.ClassByID(bnetProfile.Class); ok {
+		extensionProfile.ClassID = class.ID
+		extensionProfile.ClassKey = class.Key
+		extensionProfile.Class = class.Names.In(bnetProfile.Locale)
+		extensionProfile.ClassColor = class.Color
+	}
+	if spec, ok := selectedSpec(bnetProfile.Talents, bnetProfile.Class); ok {
+		extensionProfile.SpecID = spec.ID
+		extensionProfile.Role = string(spec.Role)
+	}
+	if race, ok := gamedata.RaceByID(bnetProfile.Race); ok {
+		extensionProfile.RaceKey = race.Key
+		extensionProfile.Race = race.Names.In(bnetProfile.Locale)
+	}
+	if faction, ok := gamedata.FactionByID(bnetProfile.Faction); ok {
+		extensionProfile.FactionKey = faction.Key
+		extensionProfile.Faction = faction.Names.In(bnetProfile.Locale)
+		if faction.Icon != "" {
+			extensionProfile.FactionIcon = fmt.Sprintf(iconPlaceholderURL, bnetProfile.Region, faction.Icon)
+		}
+	}
+	extensionProfile.Gender = genderByIndex(bnetProfile.Gender)
+	extensionProfile.Level = bnetProfile.Level
+	extensionProfile.Title = selectedTitle(bnetProfile.Titles, bnetProfile.Name)
+	extensionProfile.AchievementPoints = bnetProfile.AchievementPoints
 	extensionProfile.CharIcon = fmt.Sprintf(charIconPlaceholderURL, bnetProfile.Region, bnetProfile.Thumbnail)
-	extensionProfile.Items = getItems(bnetProfile.Items, bnetProfile.Region)
-	extensionProfile.Specs = getSpecs(bnetProfile.Talents, bnetProfile.Region)
+	// renders of the old layout share the thumbnail path, character media replaces them when available
+	extensionProfile.InsetURL = strings.Replace(extensionProfile.CharIcon, "-avatar.", "-inset.", 1)
+	extensionProfile.RenderURL = strings.Replace(extensionProfile.CharIcon, "-avatar.", "-main.", 1)
+	extensionProfile.Items = getItems(bnetProfile.Items, bnetProfile.Region, bnetProfile.Locale)
+	extensionProfile.Specs = getSpecs(bnetProfile.Talents, bnetProfile.Class, bnetProfile.Region, bnetProfile.Locale)
 	extensionProfile.ArenaRating = getArenaRating(bnetProfile.ArenaRating)
 	return &extensionProfile
 }
 
-func getSpecs(bnetTalents []bnet.SpecTalents, region string) []model.Spec {
+func getSpecs(bnetTalents []bnet.SpecTalents, classID int, region model.Region, locale string) []model.Spec {
 	specs := make([]model.Spec, 0)
 	for _, bnetSpec := range bnetTalents {
 		if len(bnetSpec.Talents) == 0 {
@@ -43,6 +68,11 @@ func getSpecs(bnetTalents []bnet.SpecTalents, region string) []model.Spec {
 
 		bnetSpecInfo := getSpecInfo(bnetSpec.Talents)
 		spec.Name = bnetSpecInfo.Name
+		if specData, ok := gamedata.SpecByOrder(classID, bnetSpecInfo.Order); ok {
+			spec.ID = specData.ID
+			spec.Key = specData.Key
+			spec.Name = specData.Names.In(locale)
+		}
 		spec.Order = bnetSpecInfo.Order
 		spec.IconURL = fmt.Sprintf(iconPlaceholderURL, region, bnetSpecInfo.Icon)
 
@@ -57,7 +87,6 @@ func getSpecs(bnetTalents []bnet.SpecTalents, region string) []model.Spec {
 			spell.ID = bnetTalent.Spell.ID
 			spell.IconURL = fmt.Sprintf(iconPlaceholderURL, region, bnetTalent.Spell.Icon)
 			spell.Name = bnetTalent.Spell.Name
-			spell.DescriptionURL = fmt.Sprintf(wowheadSpellURL, bnetTalent.Spell.ID)
 
 			talent.Spell = spell
 			talents = append(talents, talent)
@@ -68,6 +97,33 @@ func getSpecs(bnetTalents []bnet.SpecTalents, region string) []model.Spec {
 	return specs
 }
 
+// selectedTitle returns character name with the selected title, or empty string without one
+func selectedTitle(titles []bnet.Title, name string) string {
+	for _, title := range titles {
+		if title.Selected {
+			return strings.Replace(title.Name, "%s", name, 1)
+		}
+	}
+	return ""
+}
+
+func genderByIndex(idx int) string {
+	if idx == 1 {
+		return "FEMALE"
+	}
+	return "MALE"
+}
+
+// selectedSpec returns game data of the active spec
+func selectedSpec(bnetTalents []bnet.SpecTalents, classID int) (gamedata.Spec, bool) {
+	for _, bnetSpec := range bnetTalents {
+		if bnetSpec.Selected && len(bnetSpec.Talents) > 0 {
+			return gamedata.SpecByOrder(classID, getSpecInfo(bnetSpec.Talents).Order)
+		}
+	}
+	return gamedata.Spec{}, false
+}
+
 func getSpecInfo(bnetTalents []bnet.Talents) bnet.Spec {
 	for _, bnetTalent := range bnetTalents {
 		spec := bnetTalent.Spec
@@ -78,48 +134,42 @@ func getSpecInfo(bnetTalents []bnet.Talents) bnet.Spec {
 	return bnet.Spec{}
 }
 
+// getArenaRating converts brackets of the old profile layout. Ratings of the current season replace them
+// when PvP summary is available
 func getArenaRating(bnetArena bnet.ArenaRating) []model.ArenaRating {
-	arenaRating := make([]model.ArenaRating, 3)
-
 	brackets := bnetArena.Brackets
-
-	arenaRating[0] = model.ArenaRating{
-		Type:         "2v2",
-		Rating:       brackets.TwoPlayers.Rating,
-		SeasonPlayed: brackets.TwoPlayers.SeasonPlayed,
-		SeasonWon:    brackets.TwoPlayers.SeasonWon,
-		SeasonLost:   brackets.TwoPlayers.SeasonLost,
-	}
-
-	arenaRating[1] = model.ArenaRating{
-		Type:         "3v3",
-		Rating:       brackets.ThreePlayers.Rating,
-		SeasonPlayed: brackets.ThreePlayers.SeasonPlayed,
-		SeasonWon:    brackets.ThreePlayers.SeasonWon,
-		SeasonLost:   brackets.ThreePlayers.SeasonLost,
+	legacy := []struct {
+		bracket string
+		stats   bnet.ArenaStats
+	}{
+		{"2v2", brackets.TwoPlayers},
+		{"3v3", brackets.ThreePlayers},
+		{"RBG", brackets.RBG},
 	}
 
-	arenaRating[2] = model.ArenaRating{
-		Type:         "RBG",
-		Rating:       brackets.RBG.Rating,
-		SeasonPlayed: brackets.RBG.SeasonPlayed,
-		SeasonWon:    brackets.RBG.SeasonWon,
-		SeasonLost:   brackets.RBG.SeasonLost,
+	arenaRating := make([]model.ArenaRating, 0, len(legacy))
+	for _, bracket := range legacy {
+		arenaRating = append(arenaRating, model.ArenaRating{
+			Type:         bracket.bracket,
+			Bracket:      strings.ToLower(bracket.bracket),
+			Rating:       bracket.stats.Rating,
+			SeasonPlayed: bracket.stats.SeasonPlayed,
+			SeasonWon:    bracket.stats.SeasonWon,
+			SeasonLost:   bracket.stats.SeasonLost,
+		})
 	}
 	return arenaRating
 }
 
-func getItems(bnetItems bnet.Items, region string) []model.Item {
+func getItems(bnetItems bnet.Items, region model.Region, locale string) []model.Item {
 	items := make([]model.Item, 0)
 
 	reflectValue := reflect.ValueOf(bnetItems)
 
 	for i := 0; i < reflectValue.NumField(); i++ {
-		name := reflectValue.Type().Field(i).Name
-		tag := reflectValue.Type().Field(i).Tag
-		// find not necessary elements from items
-		tagValues := tag.Get("json")
-		if strings.Contains(tagValues, "omitempty") {
+		// only equipment slots are tagged
+		slot := reflectValue.Type().Field(i).Tag.Get("slot")
+		if slot == "" {
 			continue
 		}
 		item := reflectValue.Field(i).Interface().(bnet.Item)
@@ -127,93 +177,88 @@ func getItems(bnetItems bnet.Items, region string) []model.Item {
 			continue
 		}
 
-		items = append(items, convItem(item, name, region))
+		items = append(items, convItem(item, slot, region, locale))
 	}
 	return items
 }
 
-func convItem(bnetItem bnet.Item, itemType string, region string) model.Item {
+func convItem(bnetItem bnet.Item, slot string, region model.Region, locale string) model.Item {
 	item := model.Item{}
-	item.Type = itemType
+	item.ID = bnetItem.ID
+	item.Type = slot
+	if slotData, ok := gamedata.SlotByKey(slot); ok {
+		item.Slot = slotData.Names.In(locale)
+	}
 	item.Name = bnetItem.Name
 	item.ItemLvl = bnetItem.ItemLevel
-	item.IconURL = fmt.Sprintf(iconPlaceholderURL, region, bnetItem.Icon)
-	wowheadURL := fmt.Sprintf(wowheadURL, bnetItem.ID)
-	enchantmentsURL := genEnchURL(bnetItem)
-	if enchantmentsURL != "" {
-		wowheadURL = wowheadURL + "&" + enchantmentsURL
+	item.Quality = qualityByIndex(bnetItem.Quality)
+	item.Armor = bnetItem.Armor
+	item.Stats = getItemStats(bnetItem.Stats, locale)
+	item.BonusIDs = bnetItem.BonusLists
+	if item.BonusIDs == nil {
+		item.BonusIDs = make([]int, 0)
 	}
-	item.DescriptionURL = wowheadURL
+	// set name and bonuses are not part of the profile, they are described separately
+	if len(bnetItem.Enchantments.Set) > 0 {
+		item.Set = &model.ItemSet{EquippedItems: bnetItem.Enchantments.Set}
+	}
+	item.IconURL = fmt.Sprintf(iconPlaceholderURL, region, bnetItem.Icon)
+	// tooltip links are set when profile is served, by provider of the channel
+	item.Gems = getGems(bnetItem.Enchantments)
+	item.Enchantment = bnetItem.Enchantments.Enchantment
 	return item
 }
 
-func genEnchURL(bnetItem bnet.Item) string {
-	var buffer bytes.Buffer
-	notSeen := true
-	if bnetItem.Enchantments.Gem0 != 0 {
-		buffer.WriteString(addGem(bnetItem.Enchantments.Gem0, notSeen))
-		notSeen = false
-	}
-	if bnetItem.Enchantments.Gem1 != 0 {
-		buffer.WriteString(addGem(bnetItem.Enchantments.Gem1, notSeen))
-		notSeen = false
-	}
-	if bnetItem.Enchantments.Gem2 != 0 {
-		buffer.WriteString(addGem(bnetItem.Enchantments.Gem2, notSeen))
-		notSeen = false
-	}
-	if bnetItem.Enchantments.Gem3 != 0 {
-		buffer.WriteString(addGem(bnetItem.Enchantments.Gem3, notSeen))
-		notSeen = false
-	}
-	if bnetItem.Enchantments.Gem4 != 0 {
-		buffer.WriteString(addGem(bnetItem.Enchantments.Gem4, notSeen))
-		notSeen = false
-	}
-	if bnetItem.Enchantments.Enchantment != 0 {
-		if notSeen {
-			buffer.WriteString(fmt.Sprintf("ench=%d", bnetItem.Enchantments.Enchantment))
-		} else {
-			buffer.WriteString(fmt.Sprintf("&ench=%d", bnetItem.Enchantments.Enchantment))
+// getItemStats converts stats known to game data, primary stats first
+func getItemStats(bnetStats []bnet.ItemStat, locale string) []model.ItemStat {
+	stats := make([]model.ItemStat, 0, len(bnetStats))
+	for _, kind := range []gamedata.StatKind{gamedata.Primary, gamedata.Secondary, gamedata.Tertiary} {
+		for _, bnetStat := range bnetStats {
+			stat, ok := gamedata.StatByID(bnetStat.Stat)
+			if !ok || stat.Kind != kind {
+				continue
+			}
+			stats = append(stats, model.ItemStat{
+				Type:   stat.Key,
+				Name:   stat.Names.In(locale),
+				Kind:   string(stat.Kind),
+				Amount: bnetStat.Amount,
+			})
 		}
 	}
-	return buffer.String()
+	return stats
 }
 
-func addGem(id int, first bool) string {
-	if first {
-		return fmt.Sprintf("gems=%d", id)
-	}
-	return fmt.Sprintf(":%d", id)
-}
-
-func classByIndex(idx int) string {
-	var class string
+func qualityByIndex(idx int) string {
+	var quality string
 	switch idx {
+	case 0:
+		quality = "POOR"
 	case 1:
-		class = "Warrior"
+		quality = "COMMON"
 	case 2:
-		class = "Paladin"
+		quality = "UNCOMMON"
 	case 3:
-		class = "Hunter"
+		quality = "RARE"
 	case 4:
-		class = "Rogue"
+		quality = "EPIC"
 	case 5:
-		class = "Priest"
+		quality = "LEGENDARY"
 	case 6:
-		class = "Death Knight"
+		quality = "ARTIFACT"
 	case 7:
-		class = "Shaman"
-	case 8:
-		class = "Mage"
-	case 9:
-		class = "Warlock"
-	case 10:
-		class = "Monk"
-	case 11:
-		class = "Druid"
-	case 12:
-		class = "Demon Hunter"
-	}
-	return class
+		quality = "HEIRLOOM"
+	}
+	return quality
+}
+
+// getGems returns ids of socketed gems
+func getGems(enchantments bnet.Enchantments) []int {
+	gems := make([]int, 0)
+	for _, gem := range []int{enchantments.Gem0, enchantments.Gem1, enchantments.Gem2, enchantments.Gem3, enchantments.Gem4} {
+		if gem != 0 {
+			gems = append(gems, gem)
+		}
+	}
+	return gems
 }
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/salmondx/wow-twitch-extension/bnet"
+	"github.com/salmondx/wow-twitch-extension/model"
 )
 
 var specTalents = []bnet.SpecTalents{
@@ -71,10 +72,14 @@ func TestConverter(t *testing.T) {
 		t.Errorf("Region not equals")
 	}
 
-	if actual.Class != "Paladin" {
+	if actual.Class != "Paladin" || actual.ClassKey != "PALADIN" {
 		t.Errorf("Class not equals")
 	}
 
+	if actual.InsetURL != "https://render-eu.worldofwarcraft.com/character/soulflayer/51/64174899-inset.jpg" {
+		t.Errorf("Wrong inset %s", actual.InsetURL)
+	}
+
 	if len(actual.Items) != 2 {
 		t.Errorf("Not enought items")
 	}
@@ -83,6 +88,9 @@ func TestConverter(t *testing.T) {
 	if head.Name != "Fearless Combatant's Plate Helm of the Quickblade" {
 		t.Error()
 	}
+	if head.Type != "HEAD" || head.Slot != "Head" {
+		t.Errorf("Wrong slot %s (%s)", head.Slot, head.Type)
+	}
 
 	if len(actual.Specs) != 1 {
 		t.Fatalf("Spec doesn't converted")
@@ -94,13 +102,13 @@ func TestConverter(t *testing.T) {
 }
 
 func TestTalentConverter(t *testing.T) {
-	converted := getSpecs(specTalents, "eu")
+	converted := getSpecs(specTalents, 1, "eu", "de_DE")
 	if len(converted) == 0 {
 		t.Fatalf("Failed to convert")
 	}
 	spec := converted[0]
-	if spec.Name != "Arms" {
-		t.Errorf("Wrong spec name")
+	if spec.Key != "ARMS" || spec.Name != "Waffen" {
+		t.Errorf("Wrong spec name %s (%s)", spec.Name, spec.Key)
 	}
 
 	if !spec.Selected {
@@ -158,13 +166,14 @@ func TestArenaConverter(t *testing.T) {
 }
 
 func TestItemConverter(t *testing.T) {
-	region := "eu"
+	region := model.EU
 	var tests = []struct {
-		item         bnet.Item
-		itemType     string
-		wowhead      string
-		enchantments string
-		icon         string
+		item        bnet.Item
+		itemType    string
+		slot        string
+		gems        string
+		enchantment int
+		icon        string
 	}{
 		{item: bnet.Item{
 			ID:        133767,
@@ -178,9 +187,11 @@ func TestItemConverter(t *testing.T) {
 				Enchantment: 123567,
 			},
 		},
-			wowhead:  "item=133767&gems=1235:12445&ench=123567",
-			itemType: "Neck",
-			icon:     "https://render-eu.worldofwarcraft.com/icons/36/inv_7_0raid_necklace_14a.jpg",
+			gems:        "1235:12445",
+			enchantment: 123567,
+			itemType:    "NECK",
+			slot:        "Hals",
+			icon:        "https://render-eu.worldofwarcraft.com/icons/36/inv_7_0raid_necklace_14a.jpg",
 		},
 		{item: bnet.Item{
 			ID:        133767,
@@ -188,17 +199,20 @@ func TestItemConverter(t *testing.T) {
 			Name:      "t6 shoulders",
 			Icon:      "inv_7_0raid_necklace_14a",
 		},
-			wowhead:  "item=133767",
-			itemType: "Shoulders",
+			itemType: "SHOULDER",
+			slot:     "Schulter",
 			icon:     "https://render-eu.worldofwarcraft.com/icons/36/inv_7_0raid_necklace_14a.jpg",
 		},
 	}
 
 	for _, tt := range tests {
-		item := convItem(tt.item, tt.itemType, region)
+		item := convItem(tt.item, tt.itemType, region, "de_DE")
 		if item.Type != tt.itemType {
 			t.Errorf("Wrong type")
 		}
+		if item.Slot != tt.slot {
+			t.Errorf("Wrong slot name %s", item.Slot)
+		}
 		if item.Name != tt.item.Name {
 			t.Error("Names not equal")
 		}
@@ -208,35 +222,133 @@ func TestItemConverter(t *testing.T) {
 		if item.ItemLvl != tt.item.ItemLevel {
 			t.Error("Wront item lvl")
 		}
-		if item.DescriptionURL != tt.wowhead {
-			t.Error("Wrong wowhead link")
+		if joinIDs(item.Gems, ":") != tt.gems || item.Enchantment != tt.enchantment {
+			t.Errorf("Wrong gems %v or enchantment %d", item.Gems, item.Enchantment)
 		}
 	}
 }
 
 func TestClassConverter(t *testing.T) {
 	var tests = []struct {
-		in  int
-		out string
+		in     int
+		locale string
+		key    string
+		out    string
+		color  string
 	}{
-		{1, "Warrior"},
-		{2, "Paladin"},
-		{3, "Hunter"},
-		{4, "Rogue"},
-		{5, "Priest"},
-		{6, "Death Knight"},
-		{7, "Shaman"},
-		{8, "Mage"},
-		{9, "Warlock"},
-		{10, "Monk"},
-		{11, "Druid"},
-		{12, "Demon Hunter"},
+		{1, "en_US", "WARRIOR", "Warrior", "#C69B6D"},
+		{2, "en_GB", "PALADIN", "Paladin", "#F48CBA"},
+		{6, "de_DE", "DEATH_KNIGHT", "Todesritter", "#C41E3A"},
+		{8, "ru_RU", "MAGE", "Маг", "#3FC7EB"},
+		{12, "fr_FR", "DEMON_HUNTER", "Chasseur de démons", "#A330C9"},
+		{13, "pt_PT", "EVOKER", "Conjurante", "#33937F"},
+		{4, "zh_CN", "ROGUE", "潜行者", "#FFF468"},
+		{4, "xx_XX", "ROGUE", "Rogue", "#FFF468"},
+		{99, "en_US", "", "", ""},
 	}
 
 	for _, tt := range tests {
-		className := classByIndex(tt.in)
-		if className != tt.out {
-			t.Errorf("%s not equals %s by index %d", className, tt.out, tt.in)
+		profile := Convert(&bnet.CharacterProfile{Class: tt.in, Locale: tt.locale})
+		if profile.ClassKey != tt.key || profile.Class != tt.out || profile.ClassColor != tt.color {
+			t.Errorf("%s (%s) not equals %s (%s) by index %d in %s", profile.Class, profile.ClassKey, tt.out, tt.key, tt.in, tt.locale)
+		}
+	}
+}
+
+func TestSelectedSpec(t *testing.T) {
+	profile := Convert(&bnet.CharacterProfile{Class: 1, Talents: specTalents})
+	if profile.ClassID != 1 || profile.SpecID != 71 || profile.Role != "damage" {
+		t.Errorf("Wrong spec %d, role %s", profile.SpecID, profile.Role)
+	}
+	info := profile.Info()
+	if info.SpecID != 71 || info.ClassColor != "#C69B6D" {
+		t.Errorf("Spec is not in character info")
+	}
+}
+
+func TestCharacterDetails(t *testing.T) {
+	profile := Convert(&bnet.CharacterProfile{
+		Name:              "Salmond",
+		Region:            model.EU,
+		Locale:            "de_DE",
+		Class:             2,
+		Race:              10,
+		Gender:            1,
+		Faction:           1,
+		Level:             80,
+		AchievementPoints: 21450,
+		Titles: []bnet.Title{
+			{ID: 1, Name: "Private %s"},
+			{ID: 2, Name: "%s the Insane", Selected: true},
+		},
+	})
+	if profile.Race != "Blutelf" || profile.RaceKey != "BLOOD_ELF" {
+		t.Errorf("Wrong race %s (%s)", profile.Race, profile.RaceKey)
+	}
+	if profile.Faction != "Horde" || profile.FactionKey != "HORDE" {
+		t.Errorf("Wrong faction %s (%s)", profile.Faction, profile.FactionKey)
+	}
+	if profile.FactionIcon != "https://render-eu.worldofwarcraft.com/icons/36/ui_hordeicon.jpg" {
+		t.Errorf("Wrong faction icon %s", profile.FactionIcon)
+	}
+	if profile.Gender != "FEMALE" || profile.Level != 80 || profile.AchievementPoints != 21450 {
+		t.Errorf("Wrong gender %s, level %d or achievement points %d", profile.Gender, profile.Level, profile.AchievementPoints)
+	}
+	if profile.Title != "Salmond the Insane" {
+		t.Errorf("Wrong title %s", profile.Title)
+	}
+	info := profile.Info()
+	if info.Race != profile.Race || info.FactionIcon != profile.FactionIcon || info.Level != 80 || info.Title != profile.Title {
+		t.Errorf("Details are not in character info")
+	}
+}
+
+func TestItemDetails(t *testing.T) {
+	item := convItem(bnet.Item{
+		ID:         207200,
+		Name:       "Screaming Torchfiend's Burning Scowl",
+		Quality:    4,
+		Armor:      1830,
+		BonusLists: []int{6652, 7981},
+		Stats: []bnet.ItemStat{
+			{Stat: 36, Amount: 412},
+			{Stat: 62, Amount: 120},
+			{Stat: 7, Amount: 2500},
+			{Stat: 74, Amount: 740},
+			{Stat: 999, Amount: 1},
+		},
+		Enchantments: bnet.Enchantments{Set: []int{207200, 207198}},
+	}, "HEAD", model.EU, "fr_FR")
+
+	if item.ID != 207200 || item.Quality != "EPIC" || item.Armor != 1830 {
+		t.Errorf("Wrong id %d, quality %s or armor %d", item.ID, item.Quality, item.Armor)
+	}
+	if len(item.BonusIDs) != 2 || item.BonusIDs[1] != 7981 {
+		t.Errorf("Wrong bonus ids %v", item.BonusIDs)
+	}
+	var types []string
+	for _, stat := range item.Stats {
+		types = append(types, stat.Type+":"+stat.Kind)
+	}
+	expected := []string{"STAMINA:primary", "STRENGTH_INTELLECT:primary", "HASTE:secondary", "LEECH:tertiary"}
+	if len(types) != len(expected) {
+		t.Fatalf("Wrong stats %v", types)
+	}
+	for i := range expected {
+		if types[i] != expected[i] {
+			t.Errorf("Wrong stats %v", types)
+			break
 		}
 	}
+	if item.Stats[2].Name != "Hâte" || item.Stats[2].Amount != 412 {
+		t.Errorf("Wrong stat %v", item.Stats[2])
+	}
+	if item.Set == nil || len(item.Set.EquippedItems) != 2 {
+		t.Errorf("Set membership is lost")
+	}
+
+	noSet := convItem(bnet.Item{ID: 1, Name: "Ring"}, "FINGER_1", model.EU, "en_GB")
+	if noSet.Set != nil || noSet.BonusIDs == nil || noSet.Stats == nil {
+		t.Errorf("Item without set or stats is converted wrong")
+	}
 }
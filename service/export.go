@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/salmondx/wow-twitch-extension/model"
+)
+
+// ExportChannel returns stored characters of the channel with its settings
+func (s *CachableCharacterService) ExportChannel(ctx context.Context, streamerID string) (*model.ChannelExport, error) {
+	if streamerID == "" {
+		return nil, model.ValidationError{"StreamerID can not be empty"}
+	}
+	characters, err := s.storage.List(ctx, streamerID)
+	if err != nil {
+		return nil, err
+	}
+	if characters == nil {
+		characters = make([]*model.CharacterInfo, 0)
+	}
+	settings, err := s.Settings(ctx, streamerID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.ChannelExport{Version: model.ExportVersion, Characters: characters, Settings: settings}, nil
+}
+
+// ImportChannel adds characters of an export which are missing in the channel and replaces settings.
+// Characters keep their positions of the exported list, characters exported without one are put at the end.
+// Characters are validated against Battle.Net, a dry run reports results without saving anything
+func (s *CachableCharacterService) ImportChannel(ctx context.Context, streamerID string, export *model.ChannelExport, dryRun bool) (*model.ChannelImport, error) {
+	if streamerID == "" || export == nil {
+		return nil, model.ValidationError{"StreamerID or export can not be empty"}
+	}
+	if export.Version > model.ExportVersion {
+		return nil, model.ValidationError{fmt.Sprintf("Export version %d is not supported, latest is %d", export.Version, model.ExportVersion)}
+	}
+	if export.Settings != nil {
+		if err := s.validateSettings(export.Settings); err != nil {
+			return nil, err
+		}
+	}
+
+	refs := make([]model.CharacterRef, 0, len(export.Characters))
+	orders := make([]int64, 0, len(export.Characters))
+	for _, character := range export.Characters {
+		if character == nil {
+			continue
+		}
+		realm := character.RealmSlug
+		if realm == "" {
+			realm = character.Realm
+		}
+		refs = append(refs, model.CharacterRef{Region: character.Region, Realm: realm, Name: character.Name})
+		orders = append(orders, character.Order)
+	}
+
+	log.Printf("[INFO] Importing channel export for %s: %d characters, dry run %t", streamerID, len(refs), dryRun)
+	results, err := s.addMany(ctx, streamerID, refs, orders, dryRun)
+	if err != nil {
+		return nil, err
+	}
+	if export.Settings != nil && !dryRun {
+		if err := s.UpdateSettings(ctx, streamerID, export.Settings); err != nil {
+			return nil, err
+		}
+	}
+	return &model.ChannelImport{DryRun: dryRun, Characters: results, Settings: export.Settings}, nil
+}
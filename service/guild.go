@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/salmondx/wow-twitch-extension/bnet"
+	"github.com/salmondx/wow-twitch-extension/gamedata"
+	"github.com/salmondx/wow-twitch-extension/model"
+)
+
+func (s *CachableCharacterService) Guild(ctx context.Context, region model.Region, realm, name, locale string) (*model.Guild, error) {
+	if realm == "" || name == "" || region == "" {
+		return nil, model.ValidationError{"Realm or guild name can not be empty"}
+	}
+	realm, err := s.realms.Resolve(ctx, region, realm)
+	if err != nil {
+		return nil, err
+	}
+	locale = bnet.ResolveLocale(region, locale)
+	guild, err := s.cache.GetGuild(ctx, region, realm, name, locale)
+	if err != nil {
+		log.Printf("[INFO] Guild not found in cache (%s - %s, %s). Search bnet.", realm, name, locale)
+		bnetGuild, err := s.bnetClient.GetGuild(ctx, region, realm, name, locale)
+		if err != nil {
+			return nil, err
+		}
+		roster, err := s.bnetClient.GetGuildRoster(ctx, region, realm, name, locale)
+		if err != nil {
+			return nil, err
+		}
+		guild = convertGuild(bnetGuild, roster, region, realm, locale)
+		err = s.cache.AddGuild(ctx, guild)
+		if err != nil {
+			log.Printf("Can not update cache for guild %s - %s. %v", realm, name, err)
+		}
+	}
+	if s.assets != nil {
+		guild.FactionIcon = s.assets.Rewrite(guild.FactionIcon)
+	}
+	return guild, nil
+}
+
+// convertGuild converts guild profile and roster. Realm is a slug
+func convertGuild(bnetGuild *bnet.GuildProfile, roster *bnet.GuildRoster, region model.Region, realm, locale string) *model.Guild {
+	guild := &model.Guild{
+		Name:              bnetGuild.Name,
+		Realm:             bnetGuild.Realm.Name,
+		RealmSlug:         realm,
+		Region:            region,
+		Locale:            locale,
+		FactionKey:        bnetGuild.Faction.Type,
+		Faction:           bnetGuild.Faction.Name,
+		MemberCount:       bnetGuild.MemberCount,
+		AchievementPoints: bnetGuild.AchievementPoints,
+	}
+	if faction, ok := gamedata.FactionByKey(bnetGuild.Faction.Type); ok {
+		guild.Faction = faction.Names.In(locale)
+		if faction.Icon != "" {
+			guild.FactionIcon = fmt.Sprintf(iconPlaceholderURL, region, faction.Icon)
+		}
+	}
+
+	guild.Members = make([]model.GuildMember, 0, len(roster.Members))
+	for _, bnetMember := range roster.Members {
+		character := bnetMember.Character
+		member := model.GuildMember{
+			Name:      character.Name,
+			RealmSlug: character.Realm.Slug,
+			Level:     character.Level,
+			Rank:      bnetMember.Rank,
+		}
+		if class, ok := gamedata.ClassByID(character.PlayableClass.ID); ok {
+			member.ClassID = class.ID
+			member.ClassKey = class.Key
+			member.Class = class.Names.In(locale)
+			member.ClassColor = class.Color
+		}
+		if race, ok := gamedata.RaceByID(character.PlayableRace.ID); ok {
+			member.RaceKey = race.Key
+			member.Race = race.Names.In(locale)
+		}
+		guild.Members = append(guild.Members, member)
+	}
+	sort.SliceStable(guild.Members, func(i, j int) bool {
+		if guild.Members[i].Rank != guild.Members[j].Rank {
+			return guild.Members[i].Rank < guild.Members[j].Rank
+		}
+		return guild.Members[i].Name < guild.Members[j].Name
+	})
+	if guild.MemberCount == 0 {
+		guild.MemberCount = len(guild.Members)
+	}
+	return guild
+}
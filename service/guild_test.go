@@ -0,0 +1,47 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/salmondx/wow-twitch-extension/bnet"
+	"github.com/salmondx/wow-twitch-extension/model"
+)
+
+func TestGuildConverter(t *testing.T) {
+	var bnetGuild bnet.GuildProfile
+	var roster bnet.GuildRoster
+	err := json.Unmarshal([]byte(`{"name": "Method", "faction": {"type": "HORDE", "name": "Horde"},
+		"achievement_points": 3450, "member_count": 3, "realm": {"name": "Twisting Nether", "slug": "twisting-nether"}}`), &bnetGuild)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = json.Unmarshal([]byte(`{"members": [
+		{"character": {"name": "Salmond", "level": 80, "realm": {"slug": "soulflayer"}, "playable_class": {"id": 2}, "playable_race": {"id": 10}}, "rank": 4},
+		{"character": {"name": "Guildmaster", "level": 80, "realm": {"slug": "twisting-nether"}, "playable_class": {"id": 1}, "playable_race": {"id": 2}}, "rank": 0},
+		{"character": {"name": "Alt", "level": 70, "realm": {"slug": "twisting-nether"}, "playable_class": {"id": 2}, "playable_race": {"id": 2}}, "rank": 4}
+	]}`), &roster)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	guild := convertGuild(&bnetGuild, &roster, model.EU, "twisting-nether", "de_DE")
+
+	if guild.Name != "Method" || guild.Realm != "Twisting Nether" || guild.MemberCount != 3 || guild.AchievementPoints != 3450 {
+		t.Errorf("Wrong guild %v", guild)
+	}
+	if guild.FactionKey != "HORDE" || guild.Faction != "Horde" || guild.FactionIcon != "https://render-eu.worldofwarcraft.com/icons/36/ui_hordeicon.jpg" {
+		t.Errorf("Wrong faction %s (%s) %s", guild.Faction, guild.FactionKey, guild.FactionIcon)
+	}
+	if len(guild.Members) != 3 {
+		t.Fatalf("Expected 3 members, got %d", len(guild.Members))
+	}
+	for i, name := range []string{"Guildmaster", "Alt", "Salmond"} {
+		if guild.Members[i].Name != name {
+			t.Errorf("Member %d is %s, expected %s", i, guild.Members[i].Name, name)
+		}
+	}
+	if member := guild.Members[2]; member.ClassKey != "PALADIN" || member.Class != "Paladin" || member.RealmSlug != "soulflayer" || member.Rank != 4 {
+		t.Errorf("Wrong member %v", member)
+	}
+}
@@ -0,0 +1,289 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+
+	"github.com/salmondx/wow-twitch-extension/bnet"
+	"github.com/salmondx/wow-twitch-extension/gamedata"
+	"github.com/salmondx/wow-twitch-extension/model"
+)
+
+// AccountAuthorizer grants access to characters of a Battle.Net account with OAuth authorization code flow
+type AccountAuthorizer interface {
+	// AuthorizeURL returns page where account owner grants access. State is passed back with the code
+	AuthorizeURL(region model.Region, state string) (string, error)
+	// Exchange returns user access token for authorization code
+	Exchange(ctx context.Context, region model.Region, code string) (string, error)
+	// AccountProfile returns characters of the token owner
+	AccountProfile(ctx context.Context, region model.Region, token string) (*bnet.AccountProfile, error)
+}
+
+// BattleNetAccounts authorizes with Battle.Net. RedirectURL is a public URL of the import callback
+type BattleNetAccounts struct {
+	Client      *bnet.Client
+	RedirectURL string
+}
+
+func (a BattleNetAccounts) AuthorizeURL(region model.Region, state string) (string, error) {
+	return a.Client.AuthorizeURL(region, state, a.RedirectURL)
+}
+
+func (a BattleNetAccounts) Exchange(ctx context.Context, region model.Region, code string) (string, error) {
+	return a.Client.ExchangeCode(ctx, region, code, a.RedirectURL)
+}
+
+func (a BattleNetAccounts) AccountProfile(ctx context.Context, region model.Region, token string) (*bnet.AccountProfile, error) {
+	return a.Client.GetAccountProfile(ctx, region, token)
+}
+
+// localCode is the only authorization code LocalAccounts accepts
+const localCode = "local"
+
+// LocalAccounts stands in for Battle.Net in development and tests. Authorization redirects straight to the callback
+// and every account has the same characters
+type LocalAccounts struct {
+	RedirectURL string
+	Characters  []bnet.AccountCharacter
+}
+
+func (a LocalAccounts) AuthorizeURL(region model.Region, state string) (string, error) {
+	return a.RedirectURL + "?" + url.Values{"code": {localCode}, "state": {state}}.Encode(), nil
+}
+
+func (a LocalAccounts) Exchange(ctx context.Context, region model.Region, code string) (string, error) {
+	if code != localCode {
+		return "", model.ForbiddenError{"Authorization code is invalid"}
+	}
+	return localCode, nil
+}
+
+func (a LocalAccounts) AccountProfile(ctx context.Context, region model.Region, token string) (*bnet.AccountProfile, error) {
+	return &bnet.AccountProfile{WowAccounts: []bnet.WowAccount{{ID: 1, Characters: a.Characters}}}, nil
+}
+
+var importsDisabled = model.ForbiddenError{"Account import is not configured"}
+
+// StartImport starts import of characters from a Battle.Net account of the broadcaster
+func (s *CachableCharacterService) StartImport(ctx context.Context, streamerID string, region model.Region) (*model.AccountImport, error) {
+	if s.accounts == nil {
+		return nil, importsDisabled
+	}
+	if streamerID == "" || region == "" {
+		return nil, model.ValidationError{"StreamerID or region can not be empty"}
+	}
+	state, err := newImportState()
+	if err != nil {
+		return nil, err
+	}
+	authorizeURL, err := s.accounts.AuthorizeURL(region, state)
+	if err != nil {
+		return nil, err
+	}
+	accountImport := &model.AccountImport{
+		State:      state,
+		StreamerID: streamerID,
+		Region:     region,
+		Status:     model.ImportPending,
+		Characters: make([]*model.CharacterInfo, 0),
+	}
+	if err := s.cache.SaveImport(ctx, accountImport); err != nil {
+		return nil, err
+	}
+	accountImport.AuthorizeURL = authorizeURL
+	return accountImport, nil
+}
+
+// CompleteImport reads characters of the account once Battle.Net redirects the broadcaster back with a code
+func (s *CachableCharacterService) CompleteImport(ctx context.Context, state, code string) error {
+	if s.accounts == nil {
+		return importsDisabled
+	}
+	if code == "" {
+		return model.ForbiddenError{"Battle.Net account access is not granted"}
+	}
+	accountImport, err := s.findImport(ctx, state)
+	if err != nil {
+		return err
+	}
+	if accountImport.Status != model.ImportPending {
+		return model.ValidationError{"Import is already authorized"}
+	}
+	token, err := s.accounts.Exchange(ctx, accountImport.Region, code)
+	if err != nil {
+		return err
+	}
+	profile, err := s.accounts.AccountProfile(ctx, accountImport.Region, token)
+	if err != nil {
+		return err
+	}
+	existing, err := s.List(ctx, accountImport.StreamerID)
+	if err != nil {
+		return err
+	}
+	accountImport.Characters = s.withoutExisting(ctx, accountCharacters(profile, accountImport.Region), existing)
+	accountImport.Status = model.ImportReady
+	return s.cache.SaveImport(ctx, accountImport)
+}
+
+// Import returns import of the broadcaster, with characters to pick once it's ready
+func (s *CachableCharacterService) Import(ctx context.Context, streamerID, state string) (*model.AccountImport, error) {
+	accountImport, err := s.findImport(ctx, state)
+	if err != nil {
+		return nil, err
+	}
+	if accountImport.StreamerID != streamerID {
+		return nil, model.ForbiddenError{"Import belongs to another channel"}
+	}
+	return accountImport, nil
+}
+
+// ImportCharacters adds picked characters of the account in a single write. Characters already added are skipped.
+// Returns added characters
+func (s *CachableCharacterService) ImportCharacters(ctx context.Context, streamerID, state string, picked []model.CharacterRef) ([]*model.CharacterInfo, error) {
+	accountImport, err := s.Import(ctx, streamerID, state)
+	if err != nil {
+		return nil, err
+	}
+	if accountImport.Status != model.ImportReady {
+		return nil, model.ValidationError{"Battle.Net account access is not granted yet"}
+	}
+	selected, err := pickCharacters(accountImport.Characters, picked)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := s.List(ctx, streamerID)
+	if err != nil {
+		return nil, err
+	}
+	selected = s.withoutExisting(ctx, selected, existing)
+
+	log.Printf("[INFO] Importing %d characters for %s", len(selected), streamerID)
+	failed, err := s.storage.AddMany(ctx, streamerID, selected)
+	if err != nil {
+		return nil, err
+	}
+	// characters added by a concurrent request are skipped like already added ones
+	added := make([]*model.CharacterInfo, 0, len(selected))
+	for i, character := range selected {
+		if failed[i] == nil {
+			added = append(added, character)
+		}
+	}
+	selected = added
+	err = s.cache.ClearList(ctx, streamerID)
+	if err != nil {
+		log.Printf("[ERROR] Can not clear characters in cache %s. %v", streamerID, err)
+	}
+	return selected, nil
+}
+
+func (s *CachableCharacterService) findImport(ctx context.Context, state string) (*model.AccountImport, error) {
+	if state == "" {
+		return nil, model.ValidationError{"Import state can not be empty"}
+	}
+	accountImport, err := s.cache.GetImport(ctx, state)
+	if err != nil {
+		log.Printf("[INFO] Import not found. %v", err)
+		return nil, model.ValidationError{"Import is not found or expired. Start it again"}
+	}
+	return accountImport, nil
+}
+
+// withoutExisting skips characters already added to the channel
+func (s *CachableCharacterService) withoutExisting(ctx context.Context, characters, existing []*model.CharacterInfo) []*model.CharacterInfo {
+	ids := make(map[string]bool, len(existing))
+	for _, character := range existing {
+		ids[s.characterID(ctx, character)] = true
+	}
+	result := make([]*model.CharacterInfo, 0, len(characters))
+	for _, character := range characters {
+		if !ids[model.CharacterID(character.Region, character.RealmSlug, character.Name)] {
+			result = append(result, character)
+		}
+	}
+	return result
+}
+
+// accountCharacters converts characters of all WoW licenses of the account, highest level first
+func accountCharacters(profile *bnet.AccountProfile, region model.Region) []*model.CharacterInfo {
+	characters := make([]*model.CharacterInfo, 0)
+	locale := bnet.ResolveLocale(region)
+	for _, account := range profile.WowAccounts {
+		for _, bnetCharacter := range account.Characters {
+			character := &model.CharacterInfo{
+				Name:      bnetCharacter.Name,
+				Realm:     bnetCharacter.Realm.Name,
+				RealmSlug: bnetCharacter.Realm.Slug,
+				Region:    region,
+				Gender:    bnetCharacter.Gender.Type,
+				Level:     bnetCharacter.Level,
+			}
+			if class, ok := gamedata.ClassByID(bnetCharacter.PlayableClass.ID); ok {
+				character.ClassID = class.ID
+				character.ClassKey = class.Key
+				character.Class = class.Names.In(locale)
+				character.ClassColor = class.Color
+			}
+			if race, ok := gamedata.RaceByID(bnetCharacter.PlayableRace.ID); ok {
+				character.RaceKey = race.Key
+				character.Race = race.Names.In(locale)
+			}
+			if faction, ok := gamedata.FactionByKey(bnetCharacter.Faction.Type); ok {
+				character.FactionKey = faction.Key
+				character.Faction = faction.Names.In(locale)
+				if faction.Icon != "" {
+					character.FactionIcon = fmt.Sprintf(iconPlaceholderURL, region, faction.Icon)
+				}
+			}
+			characters = append(characters, character)
+		}
+	}
+	sort.SliceStable(characters, func(i, j int) bool {
+		if characters[i].Level != characters[j].Level {
+			return characters[i].Level > characters[j].Level
+		}
+		return characters[i].Name < characters[j].Name
+	})
+	return characters
+}
+
+// pickCharacters returns account characters picked by the broadcaster. Realm of a pick is a name or slug
+func pickCharacters(characters []*model.CharacterInfo, picked []model.CharacterRef) ([]*model.CharacterInfo, error) {
+	if len(picked) == 0 {
+		return nil, model.ValidationError{"No characters picked"}
+	}
+	selected := make([]*model.CharacterInfo, 0, len(picked))
+	chosen := make(map[*model.CharacterInfo]bool)
+	for _, ref := range picked {
+		var match *model.CharacterInfo
+		for _, character := range characters {
+			if character.Region == ref.Region && model.NormalizeName(character.Name) == model.NormalizeName(ref.Name) &&
+				(character.RealmSlug == ref.Realm || model.NormalizeName(character.Realm) == model.NormalizeName(ref.Realm)) {
+				match = character
+				break
+			}
+		}
+		if match == nil {
+			return nil, model.ValidationError{fmt.Sprintf("Character %s - %s is not on the Battle.Net account", ref.Realm, ref.Name)}
+		}
+		if !chosen[match] {
+			chosen[match] = true
+			selected = append(selected, match)
+		}
+	}
+	return selected, nil
+}
+
+func newImportState() (string, error) {
+	state := make([]byte, 16)
+	if _, err := rand.Read(state); err != nil {
+		return "", fmt.Errorf("Can't generate import state: %v", err)
+	}
+	return hex.EncodeToString(state), nil
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/salmondx/wow-twitch-extension/bnet"
+	"github.com/salmondx/wow-twitch-extension/model"
+)
+
+func TestLocalAccountImport(t *testing.T) {
+	accounts := LocalAccounts{
+		RedirectURL: "http://localhost:5000/import/callback",
+		Characters: []bnet.AccountCharacter{
+			{Name: "Alt", Realm: bnet.Realm{Name: "Twisting Nether", Slug: "twisting-nether"}, PlayableClass: bnet.Ref{ID: 1}, Level: 70},
+			{Name: "Salmond", Realm: bnet.Realm{Name: "Soulflayer", Slug: "soulflayer"}, PlayableClass: bnet.Ref{ID: 2},
+				PlayableRace: bnet.Ref{ID: 10}, Faction: bnet.TypeRef{Type: "HORDE"}, Gender: bnet.TypeRef{Type: "MALE"}, Level: 80},
+		},
+	}
+	ctx := context.Background()
+
+	authorizeURL, err := accounts.AuthorizeURL(model.EU, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	redirect, err := url.Parse(authorizeURL)
+	if err != nil || redirect.Query().Get("state") != "abc" {
+		t.Fatalf("State is not passed back: %s", authorizeURL)
+	}
+	if _, err := accounts.Exchange(ctx, model.EU, "forged"); err == nil {
+		t.Errorf("Invalid code is exchanged")
+	}
+	token, err := accounts.Exchange(ctx, model.EU, redirect.Query().Get("code"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile, err := accounts.AccountProfile(ctx, model.EU, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	characters := accountCharacters(profile, model.EU)
+	if len(characters) != 2 || characters[0].Name != "Salmond" {
+		t.Fatalf("Expected highest level character first, got %v", characters)
+	}
+	salmond := characters[0]
+	if salmond.RealmSlug != "soulflayer" || salmond.ClassKey != "PALADIN" || salmond.FactionKey != "HORDE" || salmond.Region != model.EU {
+		t.Errorf("Wrong character %v", salmond)
+	}
+
+	picked, err := pickCharacters(characters, []model.CharacterRef{
+		{Region: model.EU, Realm: "Twisting Nether", Name: "alt"},
+		{Region: model.EU, Realm: "soulflayer", Name: "Salmond"},
+		{Region: model.EU, Realm: "soulflayer", Name: "SALMOND"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(picked) != 2 || picked[0].Name != "Alt" {
+		t.Errorf("Wrong picked characters %v", picked)
+	}
+	if _, err := pickCharacters(characters, []model.CharacterRef{{Region: model.US, Realm: "soulflayer", Name: "Salmond"}}); err == nil {
+		t.Errorf("Character of another region is picked")
+	}
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/salmondx/wow-twitch-extension/bnet"
+	"github.com/salmondx/wow-twitch-extension/model"
+)
+
+type itemSetKey struct {
+	region model.Region
+	locale string
+	itemID int
+}
+
+// Sets never change, but only sets seen recently are kept, so the catalog doesn't grow with every item ever equipped
+const (
+	itemSetTTL        = 24 * time.Hour
+	itemSetSweepEvery = time.Hour
+)
+
+type cachedItemSet struct {
+	set    bnet.ItemSet
+	usedAt time.Time
+}
+
+// ItemSetCatalog describes item sets of equipped items. Every item is looked up once while its set is in use
+type ItemSetCatalog struct {
+	bnetClient *bnet.Client
+
+	mu      sync.Mutex
+	sets    map[itemSetKey]cachedItemSet
+	sweptAt time.Time
+}
+
+// NewItemSetCatalog creates item set catalog. Without Battle.Net client sets stay undescribed
+func NewItemSetCatalog(bnetClient *bnet.Client) *ItemSetCatalog {
+	return &ItemSetCatalog{
+		bnetClient: bnetClient,
+		sets:       make(map[itemSetKey]cachedItemSet),
+		sweptAt:    time.Now(),
+	}
+}
+
+// Describe adds name and bonuses to sets of items. Bonus is active when enough set items are equipped.
+// Pieces of the same set share one lookup, different sets are looked up simultaneously.
+// Sets that can't be retrieved are left with equipped items only, and false is returned
+func (c *ItemSetCatalog) Describe(ctx context.Context, region model.Region, locale string, items []model.Item) bool {
+	// equipped pieces of a set list each other
+	groups := make(map[string]int)
+	pieces := make([]int, 0, len(items))
+	lookups := make([]int, len(items))
+	for i := range items {
+		if items[i].Set == nil {
+			continue
+		}
+		group := fmt.Sprint(items[i].Set.EquippedItems)
+		first, ok := groups[group]
+		if !ok {
+			first = len(pieces)
+			groups[group] = first
+			pieces = append(pieces, i)
+		}
+		lookups[i] = first
+	}
+
+	sets := make([]bnet.ItemSet, len(pieces))
+	errs := make([]error, len(pieces))
+	parallel(len(pieces), func(k int) {
+		sets[k], errs[k] = c.lookup(ctx, region, locale, items[pieces[k]].ID)
+	})
+
+	complete := true
+	for i := range items {
+		set := items[i].Set
+		if set == nil {
+			continue
+		}
+		k := lookups[i]
+		if errs[k] != nil {
+			log.Printf("[WARN] Can't describe item set of %d. %v", items[i].ID, errs[k])
+			// without client sets are never described
+			complete = complete && c.bnetClient == nil
+			continue
+		}
+		bnetSet := sets[k]
+		set.ID = bnetSet.ID
+		set.Name = bnetSet.Name
+		set.Bonuses = make([]model.SetBonus, 0, len(bnetSet.SetBonuses))
+		for _, bonus := range bnetSet.SetBonuses {
+			set.Bonuses = append(set.Bonuses, model.SetBonus{
+				Threshold:   bonus.Threshold,
+				Description: bonus.Description,
+				Active:      len(set.EquippedItems) >= bonus.Threshold,
+			})
+		}
+	}
+	return complete
+}
+
+func (c *ItemSetCatalog) lookup(ctx context.Context, region model.Region, locale string, itemID int) (bnet.ItemSet, error) {
+	key := itemSetKey{region, locale, itemID}
+	c.mu.Lock()
+	cached, ok := c.sets[key]
+	if ok {
+		cached.usedAt = time.Now()
+		c.sets[key] = cached
+	}
+	c.mu.Unlock()
+	if ok {
+		return cached.set, nil
+	}
+	if c.bnetClient == nil {
+		return bnet.ItemSet{}, fmt.Errorf("Item %d is not described", itemID)
+	}
+
+	item, err := c.bnetClient.GetItem(ctx, region, itemID, locale)
+	if err != nil {
+		return bnet.ItemSet{}, err
+	}
+	set := item.ItemSet
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	// other pieces of the set share the description
+	for _, id := range append(set.Items, itemID) {
+		c.sets[itemSetKey{region, locale, id}] = cachedItemSet{set, now}
+	}
+	if now.Sub(c.sweptAt) > itemSetSweepEvery {
+		c.sweep(now)
+	}
+	return set, nil
+}
+
+// sweep removes sets unused for itemSetTTL. Caller holds the lock
+func (c *ItemSetCatalog) sweep(now time.Time) {
+	for key, cached := range c.sets {
+		if now.Sub(cached.usedAt) > itemSetTTL {
+			delete(c.sets, key)
+		}
+	}
+	c.sweptAt = now
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/salmondx/wow-twitch-extension/bnet"
+	"github.com/salmondx/wow-twitch-extension/model"
+)
+
+func TestItemSetCatalog(t *testing.T) {
+	catalog := NewItemSetCatalog(nil)
+	catalog.sets[itemSetKey{model.EU, "en_GB", 207200}] = cachedItemSet{set: bnet.ItemSet{
+		ID:   1566,
+		Name: "Screaming Torchfiend's Brutality",
+		SetBonuses: []bnet.SetBonus{
+			{Description: "2 pieces bonus", Threshold: 2},
+			{Description: "4 pieces bonus", Threshold: 4},
+		},
+	}}
+	items := []model.Item{
+		{ID: 207200, Set: &model.ItemSet{EquippedItems: []int{207200, 207198}}},
+		{ID: 207199, Set: &model.ItemSet{EquippedItems: []int{207199}}},
+		{ID: 1},
+		// another piece shares the lookup of the first one
+		{ID: 207198, Set: &model.ItemSet{EquippedItems: []int{207200, 207198}}},
+	}
+
+	if !catalog.Describe(context.Background(), model.EU, "en_GB", items) {
+		t.Errorf("Sets without client are reported as retriable")
+	}
+
+	set := items[0].Set
+	if set.ID != 1566 || set.Name != "Screaming Torchfiend's Brutality" || len(set.Bonuses) != 2 {
+		t.Fatalf("Set is not described: %v", set)
+	}
+	if !set.Bonuses[0].Active || set.Bonuses[1].Active {
+		t.Errorf("Wrong active bonuses %v", set.Bonuses)
+	}
+	if items[1].Set.Name != "" || len(items[1].Set.EquippedItems) != 1 {
+		t.Errorf("Unknown set is changed: %v", items[1].Set)
+	}
+	if items[2].Set != nil {
+		t.Errorf("Item without set got one")
+	}
+	if items[3].Set.Name != set.Name || len(items[3].Set.Bonuses) != 2 {
+		t.Errorf("Piece of the same set is not described: %v", items[3].Set)
+	}
+}
+
+func TestItemSetSweep(t *testing.T) {
+	catalog := NewItemSetCatalog(nil)
+	now := time.Now()
+	catalog.sets[itemSetKey{model.EU, "en_GB", 1}] = cachedItemSet{usedAt: now.Add(-itemSetTTL - time.Minute)}
+	catalog.sets[itemSetKey{model.EU, "en_GB", 2}] = cachedItemSet{usedAt: now.Add(-time.Minute)}
+
+	catalog.sweep(now)
+
+	if _, ok := catalog.sets[itemSetKey{model.EU, "en_GB", 1}]; ok || len(catalog.sets) != 1 {
+		t.Errorf("Only unused set should be removed, got %v", catalog.sets)
+	}
+}
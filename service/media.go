@@ -0,0 +1,40 @@
+package service
+
+import (
+	"context"
+	"log"
+
+	"github.com/salmondx/wow-twitch-extension/bnet"
+	"github.com/salmondx/wow-twitch-extension/model"
+)
+
+// describeMedia replaces renders of the old layout with character media. Realm is a slug.
+// Renders are left as is when media can't be retrieved, and false is returned if retrieval may succeed later
+func (s *CachableCharacterService) describeMedia(ctx context.Context, region model.Region, realm, name string, profile *model.Character) bool {
+	if s.bnetClient == nil {
+		return true
+	}
+	media, err := s.bnetClient.GetCharacterMedia(ctx, region, realm, name)
+	if err != nil {
+		log.Printf("[WARN] Can't retrieve character media of %s - %s. %v", realm, name, err)
+		return isNotFound(err)
+	}
+	applyMedia(media, profile)
+	return true
+}
+
+func applyMedia(media *bnet.CharacterMedia, profile *model.Character) {
+	if avatar := media.Asset(bnet.MediaAvatar); avatar != "" {
+		profile.CharIcon = avatar
+	}
+	if inset := media.Asset(bnet.MediaInset); inset != "" {
+		profile.InsetURL = inset
+	}
+	// raw render has transparent background, so the panel can draw its own
+	for _, key := range []string{bnet.MediaMainRaw, bnet.MediaMain} {
+		if render := media.Asset(key); render != "" {
+			profile.RenderURL = render
+			break
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/salmondx/wow-twitch-extension/bnet"
+	"github.com/salmondx/wow-twitch-extension/model"
+)
+
+func TestApplyMedia(t *testing.T) {
+	var media bnet.CharacterMedia
+	err := json.Unmarshal([]byte(`{"assets": [
+		{"key": "avatar", "value": "https://render.worldofwarcraft.com/eu/character/soulflayer/51/64174899-avatar.jpg"},
+		{"key": "main-raw", "value": "https://render.worldofwarcraft.com/eu/character/soulflayer/51/64174899-main-raw.png"}
+	]}`), &media)
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile := &model.Character{InsetURL: "inset.jpg", RenderURL: "main.jpg"}
+
+	applyMedia(&media, profile)
+
+	if profile.CharIcon != "https://render.worldofwarcraft.com/eu/character/soulflayer/51/64174899-avatar.jpg" {
+		t.Errorf("Wrong avatar %s", profile.CharIcon)
+	}
+	if profile.InsetURL != "inset.jpg" {
+		t.Errorf("Missing asset replaced inset with %s", profile.InsetURL)
+	}
+	if profile.RenderURL != "https://render.worldofwarcraft.com/eu/character/soulflayer/51/64174899-main-raw.png" {
+		t.Errorf("Wrong render %s", profile.RenderURL)
+	}
+}
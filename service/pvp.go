@@ -0,0 +1,193 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+
+	"github.com/salmondx/wow-twitch-extension/bnet"
+	"github.com/salmondx/wow-twitch-extension/gamedata"
+	"github.com/salmondx/wow-twitch-extension/model"
+)
+
+// bracketTypes are rating types by bracket type, in the order brackets are listed
+var bracketTypes = []struct {
+	bracket    string
+	ratingType string
+}{
+	{bnet.BracketArena2v2, "2v2"},
+	{bnet.BracketArena3v3, "3v3"},
+	{bnet.BracketBattlegrounds, "RBG"},
+	{bnet.BracketShuffle, "SHUFFLE"},
+	{bnet.BracketBlitz, "BLITZ"},
+}
+
+type pvpTierKey struct {
+	region model.Region
+	locale string
+	tierID int
+}
+
+// PvPCatalog describes ratings of the current PvP season. Tier names change only with seasons,
+// so every tier is retrieved once
+type PvPCatalog struct {
+	bnetClient *bnet.Client
+
+	mu    sync.Mutex
+	tiers map[pvpTierKey]string
+}
+
+// NewPvPCatalog creates PvP catalog. Without Battle.Net client profiles keep ratings of the old layout
+func NewPvPCatalog(bnetClient *bnet.Client) *PvPCatalog {
+	return &PvPCatalog{
+		bnetClient: bnetClient,
+		tiers:      make(map[pvpTierKey]string),
+	}
+}
+
+// Describe replaces profile ratings with brackets played in the current season. Realm is a slug.
+// Previous ratings of the character keep season highs, since Battle.Net reports current ratings only.
+// Returns false if some brackets or tiers can't be retrieved now
+func (c *PvPCatalog) Describe(ctx context.Context, region model.Region, realm, name, locale string, profile *model.Character, previous []model.ArenaRating) bool {
+	if c.bnetClient == nil {
+		return true
+	}
+	summary, err := c.bnetClient.GetPvPSummary(ctx, region, realm, name, locale)
+	if err != nil {
+		log.Printf("[WARN] Can't retrieve PvP summary of %s - %s. %v", realm, name, err)
+		return isNotFound(err)
+	}
+	fetched := make([]*bnet.PvPBracket, len(summary.Brackets))
+	parallel(len(summary.Brackets), func(i int) {
+		href := summary.Brackets[i].Href
+		bracket, err := c.bnetClient.GetPvPBracket(ctx, region, href, locale)
+		if err != nil {
+			log.Printf("[WARN] Can't retrieve PvP bracket %s. %v", href, err)
+			return
+		}
+		fetched[i] = bracket
+	})
+	complete := true
+	brackets := make([]bnet.PvPBracket, 0, len(fetched))
+	for _, bracket := range fetched {
+		if bracket == nil {
+			complete = false
+			continue
+		}
+		brackets = append(brackets, *bracket)
+	}
+	ratings, described := c.ratings(ctx, region, locale, brackets, previous)
+	profile.ArenaRating = ratings
+	return complete && described
+}
+
+// ratings converts brackets. Returns false if some tier names can't be retrieved
+func (c *PvPCatalog) ratings(ctx context.Context, region model.Region, locale string, brackets []bnet.PvPBracket, previous []model.ArenaRating) ([]model.ArenaRating, bool) {
+	tiers, complete := c.tierNames(ctx, region, locale, brackets)
+	ratings := make([]model.ArenaRating, 0, len(brackets))
+	for _, bracket := range brackets {
+		rating := model.ArenaRating{
+			Type:         ratingType(bracket.Bracket.Type),
+			Bracket:      bracket.Slug,
+			Rating:       bracket.Rating,
+			SeasonID:     bracket.Season.ID,
+			SeasonHigh:   bracket.Rating,
+			SeasonPlayed: bracket.SeasonMatchStatistics.Played,
+			SeasonWon:    bracket.SeasonMatchStatistics.Won,
+			SeasonLost:   bracket.SeasonMatchStatistics.Lost,
+			WeeklyPlayed: bracket.WeeklyMatchStatistics.Played,
+			WeeklyWon:    bracket.WeeklyMatchStatistics.Won,
+			WeeklyLost:   bracket.WeeklyMatchStatistics.Lost,
+		}
+		if rating.Bracket == "" {
+			rating.Bracket = bracket.Bracket.Type
+		}
+		if spec, ok := gamedata.SpecByID(bracket.Specialization.ID); ok {
+			rating.SpecID = spec.ID
+			rating.Spec = spec.Names.In(locale)
+		}
+		if bracket.Tier.ID != 0 {
+			rating.Tier = tiers[bracket.Tier.ID]
+		}
+		for _, prev := range previous {
+			if prev.Bracket == rating.Bracket && prev.SeasonID == rating.SeasonID && prev.SeasonHigh > rating.SeasonHigh {
+				rating.SeasonHigh = prev.SeasonHigh
+			}
+		}
+		ratings = append(ratings, rating)
+	}
+	sort.SliceStable(ratings, func(i, j int) bool {
+		if ratings[i].Type != ratings[j].Type {
+			return ratingOrder(ratings[i].Type) < ratingOrder(ratings[j].Type)
+		}
+		return ratings[i].Bracket < ratings[j].Bracket
+	})
+	return ratings, complete
+}
+
+// tierNames returns names of bracket tiers by id, retrieving them simultaneously.
+// Returns false if some tiers can't be retrieved
+func (c *PvPCatalog) tierNames(ctx context.Context, region model.Region, locale string, brackets []bnet.PvPBracket) (map[int]string, bool) {
+	refs := make([]bnet.Ref, 0, len(brackets))
+	seen := make(map[int]bool, len(brackets))
+	for _, bracket := range brackets {
+		if bracket.Tier.ID != 0 && !seen[bracket.Tier.ID] {
+			seen[bracket.Tier.ID] = true
+			refs = append(refs, bracket.Tier)
+		}
+	}
+	names := make([]string, len(refs))
+	retrieved := make([]bool, len(refs))
+	parallel(len(refs), func(i int) {
+		names[i], retrieved[i] = c.tierName(ctx, region, locale, refs[i])
+	})
+	tiers := make(map[int]string, len(refs))
+	complete := true
+	for i, ref := range refs {
+		tiers[ref.ID] = names[i]
+		complete = complete && retrieved[i]
+	}
+	return tiers, complete
+}
+
+// tierName returns localized tier name, or false if the tier can't be retrieved
+func (c *PvPCatalog) tierName(ctx context.Context, region model.Region, locale string, tier bnet.Ref) (string, bool) {
+	key := pvpTierKey{region, locale, tier.ID}
+	c.mu.Lock()
+	name, ok := c.tiers[key]
+	c.mu.Unlock()
+	if ok || c.bnetClient == nil {
+		return name, true
+	}
+
+	pvpTier, err := c.bnetClient.GetPvPTier(ctx, region, tier, locale)
+	if err != nil {
+		log.Printf("[WARN] Can't retrieve PvP tier %d. %v", tier.ID, err)
+		return "", false
+	}
+	c.mu.Lock()
+	c.tiers[key] = pvpTier.Name
+	c.mu.Unlock()
+	return pvpTier.Name, true
+}
+
+// ratingType returns rating type of a bracket type. Unknown brackets keep their type
+func ratingType(bracket string) string {
+	for _, t := range bracketTypes {
+		if t.bracket == bracket {
+			return t.ratingType
+		}
+	}
+	return bracket
+}
+
+// ratingOrder returns position of a rating type in the list, unknown types go last
+func ratingOrder(ratingType string) int {
+	for i, t := range bracketTypes {
+		if t.ratingType == ratingType {
+			return i
+		}
+	}
+	return len(bracketTypes)
+}
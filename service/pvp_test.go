@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/salmondx/wow-twitch-extension/bnet"
+	"github.com/salmondx/wow-twitch-extension/model"
+)
+
+func TestPvPRatings(t *testing.T) {
+	var brackets []bnet.PvPBracket
+	err := json.Unmarshal([]byte(`[
+		{"bracket": {"type": "SHUFFLE"}, "rating": 2100, "season": {"id": 37}, "tier": {"id": 5},
+		 "specialization": {"id": 72}, "season_match_statistics": {"played": 60, "won": 35, "lost": 25}},
+		{"bracket": {"type": "ARENA_3v3"}, "rating": 1800, "season": {"id": 37},
+		 "weekly_match_statistics": {"played": 4, "won": 3, "lost": 1}},
+		{"bracket": {"type": "SHUFFLE"}, "rating": 1500, "season": {"id": 37}, "specialization": {"id": 71}}
+	]`), &brackets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	brackets[0].Slug = "shuffle-warrior-fury"
+	brackets[1].Slug = "3v3"
+	brackets[2].Slug = "shuffle-warrior-arms"
+	catalog := NewPvPCatalog(nil)
+	catalog.tiers[pvpTierKey{model.EU, "de_DE", 5}] = "Duellant"
+	previous := []model.ArenaRating{
+		{Type: "3v3", Bracket: "3v3", SeasonID: 37, SeasonHigh: 1950},
+		{Type: "SHUFFLE", Bracket: "shuffle-warrior-arms", SeasonID: 36, SeasonHigh: 2400},
+	}
+
+	ratings, complete := catalog.ratings(context.Background(), model.EU, "de_DE", brackets, previous)
+
+	if !complete {
+		t.Errorf("Cached tier is reported as missing")
+	}
+	if len(ratings) != 3 {
+		t.Fatalf("Expected 3 ratings, got %v", ratings)
+	}
+	threes, arms, fury := ratings[0], ratings[1], ratings[2]
+	if threes.Type != "3v3" || threes.SeasonHigh != 1950 || threes.WeeklyWon != 3 {
+		t.Errorf("Wrong 3v3 rating %v", threes)
+	}
+	if arms.Bracket != "shuffle-warrior-arms" || arms.Spec != "Waffen" || arms.SeasonHigh != 1500 {
+		t.Errorf("Season high of previous season is kept: %v", arms)
+	}
+	if fury.Type != "SHUFFLE" || fury.SpecID != 72 || fury.Tier != "Duellant" || fury.SeasonPlayed != 60 {
+		t.Errorf("Wrong shuffle rating %v", fury)
+	}
+}
+
+func TestParallel(t *testing.T) {
+	var running, peak int32
+	called := make([]bool, 10)
+	parallel(len(called), func(i int) {
+		current := atomic.AddInt32(&running, 1)
+		for {
+			max := atomic.LoadInt32(&peak)
+			if current <= max || atomic.CompareAndSwapInt32(&peak, max, current) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		called[i] = true
+		atomic.AddInt32(&running, -1)
+	})
+	for i, ok := range called {
+		if !ok {
+			t.Errorf("Index %d is skipped", i)
+		}
+	}
+	if peak > maxParallelRequests {
+		t.Errorf("%d calls run at once, limit is %d", peak, maxParallelRequests)
+	}
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/salmondx/wow-twitch-extension/bnet"
+	"github.com/salmondx/wow-twitch-extension/model"
+)
+
+// realms.json is a snapshot of Battle.Net realm index, used while Battle.Net is unavailable
+//
+//go:embed realms.json
+var realmsFixture []byte
+
+// Realm index changes only when realms are added or renamed
+const realmCatalogTTL = 24 * time.Hour
+
+// realmRetryInterval delays loading of the index after a failure, so requests don't wait for Battle.Net every time
+const realmRetryInterval = time.Minute
+
+// regionRealms is a realm index loaded from Battle.Net. Slugs are nil until the first successful load
+type regionRealms struct {
+	slugs    map[string]string
+	loadedAt time.Time
+	failedAt time.Time
+}
+
+// RealmCatalog resolves realm display names to slugs using Battle.Net realm index
+type RealmCatalog struct {
+	bnetClient *bnet.Client
+	fixture    map[model.Region][]bnet.Realm
+
+	mu      sync.Mutex
+	regions map[model.Region]*regionRealms
+	// loading has loads of realm indexes in progress, closed when a load ends
+	loading map[model.Region]chan struct{}
+}
+
+// NewRealmCatalog creates realm catalog. Without Battle.Net client only the bundled realm snapshot is used
+func NewRealmCatalog(bnetClient *bnet.Client) *RealmCatalog {
+	var fixture map[model.Region][]bnet.Realm
+	if err := json.Unmarshal(realmsFixture, &fixture); err != nil {
+		log.Fatalf("Can't read realms snapshot: %v", err)
+	}
+	return &RealmCatalog{
+		bnetClient: bnetClient,
+		fixture:    fixture,
+		regions:    make(map[model.Region]*regionRealms),
+		loading:    make(map[model.Region]chan struct{}),
+	}
+}
+
+// Resolve returns slug of a realm given by its display name or slug in any case.
+// RealmNotFoundError is returned if Battle.Net realm index of the region has no such realm.
+// Without the index realms unknown to the snapshot are guessed from their names
+func (c *RealmCatalog) Resolve(ctx context.Context, region model.Region, realm string) (string, error) {
+	slugs, authoritative := c.realms(ctx, region)
+	if slug, ok := slugs[model.Slug(realm)]; ok {
+		return slug, nil
+	}
+	if !authoritative {
+		return model.Slug(realm), nil
+	}
+	return "", model.RealmNotFoundError{fmt.Sprintf("Realm %s not found in %s", realm, region)}
+}
+
+// realms returns realm index of a region. It's authoritative when loaded from Battle.Net,
+// otherwise it's the bundled snapshot, which may be nil for unknown regions
+func (c *RealmCatalog) realms(ctx context.Context, region model.Region) (map[string]string, bool) {
+	c.mu.Lock()
+	cached, ok := c.regions[region]
+	c.mu.Unlock()
+	if ok && cached.slugs != nil && time.Since(cached.loadedAt) < realmCatalogTTL {
+		return cached.slugs, true
+	}
+
+	if c.bnetClient != nil && (!ok || time.Since(cached.failedAt) >= realmRetryInterval) {
+		cached = c.load(ctx, region)
+	}
+	// stale index is still better than the snapshot
+	if cached != nil && cached.slugs != nil {
+		return cached.slugs, true
+	}
+	return indexRealms(c.fixture[region]), false
+}
+
+// load waits for realm index of a region loaded from Battle.Net. Concurrent requests of a region share one load
+func (c *RealmCatalog) load(ctx context.Context, region model.Region) *regionRealms {
+	c.mu.Lock()
+	done, ok := c.loading[region]
+	if !ok {
+		done = make(chan struct{})
+		c.loading[region] = done
+		// load is shared, so it must outlive the request that started it
+		go c.loadShared(context.WithoutCancel(ctx), region, done)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.regions[region]
+}
+
+// loadShared loads realm index for all requests waiting for done
+func (c *RealmCatalog) loadShared(ctx context.Context, region model.Region, done chan struct{}) {
+	realms, err := c.bnetClient.GetRealms(ctx, region)
+	c.mu.Lock()
+	defer close(done)
+	defer c.mu.Unlock()
+	delete(c.loading, region)
+	if err == nil {
+		c.regions[region] = &regionRealms{slugs: indexRealms(realms), loadedAt: time.Now()}
+		return
+	}
+	log.Printf("[WARN] Can't load realms of %s. %v", region, err)
+	failed := regionRealms{failedAt: time.Now()}
+	if previous, ok := c.regions[region]; ok {
+		failed.slugs, failed.loadedAt = previous.slugs, previous.loadedAt
+	}
+	c.regions[region] = &failed
+}
+
+// indexRealms maps both realm names and slugs to slugs
+func indexRealms(realms []bnet.Realm) map[string]string {
+	slugs := make(map[string]string, 2*len(realms))
+	for _, realm := range realms {
+		slugs[model.Slug(realm.Name)] = realm.Slug
+		slugs[model.Slug(realm.Slug)] = realm.Slug
+	}
+	return slugs
+}
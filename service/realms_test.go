@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/salmondx/wow-twitch-extension/model"
+)
+
+func TestRealmCatalog(t *testing.T) {
+	catalog := NewRealmCatalog(nil)
+	var tests = []struct {
+		region model.Region
+		realm  string
+		slug   string
+	}{
+		{"eu", "Twisting Nether", "twisting-nether"},
+		{"eu", "twisting-nether", "twisting-nether"},
+		{"eu", "Twisting-Nether", "twisting-nether"},
+		{"eu", "Kel'Thuzad", "kelthuzad"},
+		{"eu", "Azjol-Nerub", "azjolnerub"},
+		{"eu", "Свежеватель Душ", "soulflayer"},
+		{"eu", "СВЕЖЕВАТЕЛЬ ДУШ", "soulflayer"},
+		{"eu", "Soulflayer", "soulflayer"},
+		{"us", "Area 52", "area-52"},
+		{"kr", "아즈샤라", "azshara"},
+	}
+	for _, tt := range tests {
+		slug, err := catalog.Resolve(context.Background(), tt.region, tt.realm)
+		if err != nil {
+			t.Errorf("%s - %s not resolved: %v", tt.region, tt.realm, err)
+			continue
+		}
+		if slug != tt.slug {
+			t.Errorf("%s - %s resolved to %s, expected %s", tt.region, tt.realm, slug, tt.slug)
+		}
+	}
+
+	// realms missing in the snapshot are guessed until Battle.Net index is loaded
+	slug, err := catalog.Resolve(context.Background(), "eu", "Illidan")
+	if err != nil || slug != "illidan" {
+		t.Errorf("Realm is not guessed from snapshot: %s, %v", slug, err)
+	}
+
+	catalog.regions[model.EU] = &regionRealms{slugs: indexRealms(catalog.fixture[model.EU]), loadedAt: time.Now()}
+	_, err = catalog.Resolve(context.Background(), "eu", "Illidan")
+	if _, ok := err.(model.RealmNotFoundError); !ok {
+		t.Errorf("US realm resolved in EU: %v", err)
+	}
+}
+
+func TestCharacterID(t *testing.T) {
+	// "é" composed and decomposed
+	composed := model.CharacterID(model.EU, "twisting-nether", "Andr\u00e9")
+	decomposed := model.CharacterID(model.EU, "twisting-nether", "ANDRE\u0301")
+	if composed != decomposed {
+		t.Errorf("%s not equals %s", composed, decomposed)
+	}
+}
@@ -1,10 +1,12 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
 	"sync"
+	"time"
 
 	"github.com/salmondx/wow-twitch-extension/bnet"
 
@@ -15,179 +17,357 @@ import (
 
 // CharacterService allows to view currently added WoW characters,
 // add new characters, delete character and get a detailed info of selected character
+// All methods stop waiting for Battle.Net, cache and storage once ctx is done
 type CharacterService interface {
 	// Get short characters info. Returns empty slice if no characters
-	List(streamerID string) ([]*model.CharacterInfo, error)
+	List(ctx context.Context, streamerID string) ([]*model.CharacterInfo, error)
 	// Add new character to storage. If character exists with such realm - name pair, error is thrown
-	Add(streamerID, region, realm, name string) error
+	Add(ctx context.Context, streamerID string, region model.Region, realm, name string) error
 	// Delete character from storage
-	Delete(streamerID, region, realm, name string) error
-	// Retrieve full character profile
-	Profile(streamerID, region, realm, name string) (*model.Character, error)
+	Delete(ctx context.Context, streamerID string, region model.Region, realm, name string) error
+	// Add characters with a single write. Result of every character is reported separately
+	AddMany(ctx context.Context, streamerID string, characters []model.CharacterRef) ([]model.BatchResult, error)
+	// Delete characters with a single write. Result of every character is reported separately
+	DeleteMany(ctx context.Context, streamerID string, characters []model.CharacterRef) ([]model.BatchResult, error)
+	// Retrieve full character profile. Names are localized with locale, empty locale stands for region default.
+	// Tooltip links lead to provider selected by the channel
+	Profile(ctx context.Context, streamerID string, region model.Region, realm, name, locale string) (*model.Character, error)
+	// Get channel settings. Channels without saved settings get defaults
+	Settings(ctx context.Context, streamerID string) (*model.ChannelSettings, error)
+	// Replace channel settings
+	UpdateSettings(ctx context.Context, streamerID string, settings *model.ChannelSettings) error
+	// Export characters of the channel with its settings
+	ExportChannel(ctx context.Context, streamerID string) (*model.ChannelExport, error)
+	// Add missing characters of an export and replace settings. Nothing is saved in a dry run
+	ImportChannel(ctx context.Context, streamerID string, export *model.ChannelExport, dryRun bool) (*model.ChannelImport, error)
+	// Retrieve guild profile with its roster. Names are localized with locale, empty locale stands for region default
+	Guild(ctx context.Context, region model.Region, realm, name, locale string) (*model.Guild, error)
+	// Start import of characters from a Battle.Net account. Broadcaster authorizes on the returned AuthorizeURL
+	StartImport(ctx context.Context, streamerID string, region model.Region) (*model.AccountImport, error)
+	// Complete authorization of an import with a code Battle.Net redirected back with
+	CompleteImport(ctx context.Context, state, code string) error
+	// Get import of the channel
+	Import(ctx context.Context, streamerID, state string) (*model.AccountImport, error)
+	// Add picked characters of an authorized import. Returns added characters
+	ImportCharacters(ctx context.Context, streamerID, state string, picked []model.CharacterRef) ([]*model.CharacterInfo, error)
 }
 
 // CachableCharacterService implements CharacterService interface
 // It caches and stores data in db. If not found, searches data in Bnet.API
 type CachableCharacterService struct {
-	cache      cache.Cache
-	storage    storage.CharacterRepository
-	bnetClient *bnet.Client
+	cache           cache.Cache
+	storage         storage.CharacterRepository
+	settingsStorage storage.SettingsRepository
+	bnetClient      *bnet.Client
+	realms          *RealmCatalog
+	itemSets        *ItemSetCatalog
+	talents         *TalentCatalog
+	pvp             *PvPCatalog
+	tooltips        TooltipProviders
+	assets          AssetRewriter
+	accounts        AccountAuthorizer
+
+	settingsMu      sync.Mutex
+	settings        map[string]cachedSettings
+	settingsSweptAt time.Time
 }
 
-func New(cache cache.Cache, storage storage.CharacterRepository, bnetClient *bnet.Client) *CachableCharacterService {
+func New(cache cache.Cache, storage storage.CharacterRepository, settingsStorage storage.SettingsRepository,
+	bnetClient *bnet.Client, tooltips TooltipProviders, assets AssetRewriter, accounts AccountAuthorizer) *CachableCharacterService {
 	return &CachableCharacterService{
-		cache:      cache,
-		storage:    storage,
-		bnetClient: bnetClient,
+		cache:           cache,
+		storage:         storage,
+		settingsStorage: settingsStorage,
+		bnetClient:      bnetClient,
+		realms:          NewRealmCatalog(bnetClient),
+		itemSets:        NewItemSetCatalog(bnetClient),
+		talents:         NewTalentCatalog(bnetClient),
+		pvp:             NewPvPCatalog(bnetClient),
+		tooltips:        tooltips,
+		assets:          assets,
+		accounts:        accounts,
+		settings:        make(map[string]cachedSettings),
 	}
 }
 
-func (s *CachableCharacterService) List(streamerID string) ([]*model.CharacterInfo, error) {
+func (s *CachableCharacterService) List(ctx context.Context, streamerID string) ([]*model.CharacterInfo, error) {
 	if streamerID == "" {
-		return nil, errors.New("StreamerID can not be empty")
+		return nil, model.ValidationError{"StreamerID can not be empty"}
 	}
-	characters, err := s.cache.List(streamerID)
+	characters, err := s.cache.List(ctx, streamerID)
 	// expired cache info
 	if err != nil {
 		log.Printf("[WARN] Can't retrive characters list from cache: %s. %v", streamerID, err)
 		// get characters list from db
-		characters, err = s.storage.List(streamerID)
+		characters, err = s.storage.List(ctx, streamerID)
 		if err != nil {
 			return nil, err
 		}
 		// Get updated character info from bnet
-		updatedInfo, err := s.getCharactersInfo(characters)
-		if err != err {
+		// a viewer waits for the list, so the refresh keeps priority of the caller. The admin CLI runs in background
+		updatedInfo, err := s.getCharactersInfo(ctx, streamerID, characters)
+		if err != nil {
 			return nil, err
 		}
-		err = s.cache.AddCharacters(streamerID, updatedInfo)
+		// partially refreshed list must not be cached
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		err = s.cache.AddCharacters(ctx, streamerID, updatedInfo)
 		if err != nil {
 			log.Printf("[ERROR] %v", err)
 		}
+		characters = updatedInfo
 	}
 	if characters == nil {
 		characters = make([]*model.CharacterInfo, 0)
 	}
+	rewriteInfoAssets(s.assets, characters)
 	return characters, nil
 }
 
-func (s *CachableCharacterService) Add(streamerID, region, realm, name string) error {
+func (s *CachableCharacterService) Add(ctx context.Context, streamerID string, region model.Region, realm, name string) error {
 	if missingRequiredParameters(streamerID, region, realm, name) {
-		return errors.New("StreamerID, realm or name can not be empty")
+		return model.ValidationError{"StreamerID, realm or name can not be empty"}
 	}
-	bnetProfile, err := s.bnetClient.GetCharacterProfile(region, realm, name)
+	realm, err := s.realms.Resolve(ctx, region, realm)
 	if err != nil {
 		return err
 	}
-	profile := Convert(bnetProfile)
-	charInfo := model.CharacterInfo{
-		CharIcon: profile.CharIcon,
-		Class:    profile.Class,
-		Name:     profile.Name,
-		Realm:    profile.Realm,
-		Region:   profile.Region,
-		Guild:    profile.Guild,
-		ItemLvl:  profile.ItemLvl,
+	bnetProfile, err := s.bnetClient.GetCharacterProfile(ctx, region, realm, name)
+	if err != nil {
+		return err
 	}
+	profile := Convert(bnetProfile)
+	profile.RealmSlug = realm
+	charInfo := profile.Info()
 
 	// Trying to search characters for duplications
-	characters, err := s.List(streamerID)
+	characters, err := s.List(ctx, streamerID)
 	if err != nil {
 		return err
 	}
-	if characters != nil {
-		for _, character := range characters {
-			if character.Realm == realm && character.Name == name {
-				return model.CharacterDuplicateError{fmt.Sprintf("Character with name %s on realm %s already exists", name, realm)}
-			}
+	id := model.CharacterID(region, realm, name)
+	for _, character := range characters {
+		if s.characterID(ctx, character) == id {
+			return model.CharacterDuplicateError{fmt.Sprintf("Character with name %s on realm %s already exists", profile.Name, profile.Realm)}
 		}
 	}
 	// Add new character
-	err = s.storage.Add(streamerID, &charInfo)
+	err = s.storage.Add(ctx, streamerID, charInfo)
 	if err != nil {
 		return err
 	}
 	// update characters in cache
-	err = s.cache.Update(streamerID, profile)
+	err = s.cache.Update(ctx, streamerID, profile, bnetProfile.Validators)
 	if err != nil {
 		log.Printf("[ERROR] Can not save profile in cache %s. %v", streamerID, err)
 	}
 	return nil
 }
 
-func (s *CachableCharacterService) Delete(streamerID, region, realm, name string) error {
+func (s *CachableCharacterService) Delete(ctx context.Context, streamerID string, region model.Region, realm, name string) error {
 	if missingRequiredParameters(streamerID, region, realm, name) {
-		return errors.New("StreamerID, realm or name can not be empty")
+		return model.ValidationError{"StreamerID, realm or name can not be empty"}
 	}
-	err := s.storage.Delete(streamerID, region, realm, name)
+	realm, err := s.realms.Resolve(ctx, region, realm)
 	if err != nil {
 		return err
 	}
-	err = s.cache.ClearList(streamerID)
+	err = s.storage.Delete(ctx, streamerID, region, realm, name)
+	if err != nil {
+		return err
+	}
+	err = s.cache.ClearList(ctx, streamerID)
 	if err != nil {
 		log.Printf("[ERROR] Can not delete character from cache: %s, %s - %s. %v", streamerID, realm, name, err)
 	}
 	return nil
 }
 
-func (s *CachableCharacterService) Profile(streamerID, region, realm, name string) (*model.Character, error) {
+func (s *CachableCharacterService) Profile(ctx context.Context, streamerID string, region model.Region, realm, name, locale string) (*model.Character, error) {
 	if missingRequiredParameters(streamerID, region, realm, name) {
-		return nil, errors.New("StreamerID, realm or name can not be empty")
+		return nil, model.ValidationError{"StreamerID, realm or name can not be empty"}
 	}
-	profile, err := s.cache.GetProfile(streamerID, region, realm, name)
+	realm, err := s.realms.Resolve(ctx, region, realm)
 	if err != nil {
-		log.Printf("[INFO] %s profile not found in cache (%s - %s). Search bnet.", streamerID, realm, name)
-		bnetProfile, err := s.bnetClient.GetCharacterProfile(region, realm, name)
+		return nil, err
+	}
+	locale = bnet.ResolveLocale(region, locale)
+	profile, err := s.cache.GetProfile(ctx, streamerID, region, realm, name, locale)
+	if err != nil {
+		log.Printf("[INFO] %s profile not found in cache (%s - %s, %s). Search bnet.", streamerID, realm, name, locale)
+		profile, err = s.refreshProfile(ctx, streamerID, region, realm, name, locale)
 		if err != nil {
 			return nil, err
 		}
-		profile = Convert(bnetProfile)
-		err = s.cache.AddProfile(streamerID, profile)
+	}
+	s.tooltips.Link(s.tooltipProvider(ctx, streamerID), profile)
+	rewriteProfileAssets(s.assets, profile)
+	return profile, nil
+}
+
+// refreshProfile retrieves profile from Bnet.API. Expired profile from cache is revalidated
+// and only its expiration is extended if Bnet.API reports it's not modified
+func (s *CachableCharacterService) refreshProfile(ctx context.Context, streamerID string, region model.Region, realm, name, locale string) (*model.Character, error) {
+	stale, validators, err := s.cache.GetStaleProfile(ctx, streamerID, region, realm, name, locale)
+	if err != nil {
+		stale = nil
+		validators = bnet.Validators{}
+	}
+	bnetProfile, modified, err := s.bnetClient.GetCharacterProfileIfModified(ctx, region, realm, name, locale, validators)
+	if err != nil {
+		return nil, err
+	}
+	if !modified && stale != nil {
+		err = s.cache.Touch(ctx, streamerID, region, realm, name, locale)
 		if err != nil {
-			log.Printf("Can not update cache for %s. %v", streamerID, err)
+			log.Printf("Can not extend cache for %s. %v", streamerID, err)
 		}
+		return stale, nil
+	}
+	if bnetProfile == nil {
+		// validators were sent without a cached profile, which should never happen
+		return nil, fmt.Errorf("Profile %s - %s is not modified, but not found in cache", realm, name)
+	}
+	profile := Convert(bnetProfile)
+	profile.RealmSlug = realm
+	complete := s.itemSets.Describe(ctx, region, profile.Locale, profile.Items)
+	complete = s.talents.Describe(ctx, region, realm, name, profile.Locale, profile) && complete
+	var previous []model.ArenaRating
+	if stale != nil {
+		previous = stale.ArenaRating
+	}
+	complete = s.pvp.Describe(ctx, region, realm, name, profile.Locale, profile, previous) && complete
+	complete = s.describeMedia(ctx, region, realm, name, profile) && complete
+	if complete {
+		err = s.cache.AddProfile(ctx, streamerID, profile, bnetProfile.Validators)
+	} else {
+		// without validators the profile is downloaded and described again once it expires
+		log.Printf("[INFO] Profile %s - %s is partially described, caching it briefly", realm, name)
+		err = s.cache.AddPartialProfile(ctx, streamerID, profile)
+	}
+	if err != nil {
+		log.Printf("Can not update cache for %s. %v", streamerID, err)
 	}
 	return profile, nil
 }
 
-func missingRequiredParameters(streamerID, region, realm, name string) bool {
+// characterID returns id of a saved character. Characters saved before realm slugs were introduced
+// are identified by resolving their realm names
+func (s *CachableCharacterService) characterID(ctx context.Context, character *model.CharacterInfo) string {
+	return model.CharacterID(character.Region, s.realmSlug(ctx, character), character.Name)
+}
+
+func (s *CachableCharacterService) realmSlug(ctx context.Context, character *model.CharacterInfo) string {
+	if character.RealmSlug != "" {
+		return character.RealmSlug
+	}
+	slug, err := s.realms.Resolve(ctx, character.Region, character.Realm)
+	if err != nil {
+		return model.Slug(character.Realm)
+	}
+	return slug
+}
+
+func missingRequiredParameters(streamerID string, region model.Region, realm, name string) bool {
 	return streamerID == "" || realm == "" || name == "" || region == ""
 }
 
-func (s *CachableCharacterService) getCharactersInfo(oldInfo []*model.CharacterInfo) ([]*model.CharacterInfo, error) {
+// revalidateProfile retrieves profile in default locale of the region. Profile cached for the streamer is returned
+// with cached set when Battle.Net reports it's not modified, otherwise the downloaded profile is not described yet
+func (s *CachableCharacterService) revalidateProfile(ctx context.Context, streamerID string, region model.Region, realm, name string) (*model.Character, bnet.Validators, bool, error) {
+	locale := bnet.ResolveLocale(region, "")
+	stale, validators, err := s.cache.GetStaleProfile(ctx, streamerID, region, realm, name, locale)
+	if err != nil {
+		stale = nil
+		validators = bnet.Validators{}
+	}
+	bnetProfile, modified, err := s.bnetClient.GetCharacterProfileIfModified(ctx, region, realm, name, locale, validators)
+	if err != nil {
+		return nil, bnet.Validators{}, false, err
+	}
+	if !modified && stale != nil {
+		return stale, validators, true, nil
+	}
+	if bnetProfile == nil {
+		return nil, bnet.Validators{}, false, fmt.Errorf("Profile %s - %s is not modified, but not found in cache", realm, name)
+	}
+	profile := Convert(bnetProfile)
+	profile.RealmSlug = realm
+	return profile, bnetProfile.Validators, false, nil
+}
+
+// maxParallelRequests limits simultaneous Battle.Net requests describing a single profile
+const maxParallelRequests = 4
+
+// parallel calls fn for indexes from 0 to n, at most maxParallelRequests at a time, and waits for all calls
+func parallel(n int, fn func(i int)) {
+	slots := make(chan struct{}, maxParallelRequests)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// isNotFound reports Battle.Net having no data of a character, which retrying won't change
+func isNotFound(err error) bool {
+	var notFound model.CharacterNotFound
+	return errors.As(err, &notFound)
+}
+
+// getCharactersInfo downloads characters in the order they were saved, so cached lists and their ETags are stable.
+// Cached profiles are revalidated instead of downloaded again
+func (s *CachableCharacterService) getCharactersInfo(ctx context.Context, streamerID string, oldInfo []*model.CharacterInfo) ([]*model.CharacterInfo, error) {
 	if len(oldInfo) == 0 {
 		return []*model.CharacterInfo{}, nil
 	}
 	var wg sync.WaitGroup
 	var lock sync.Mutex
 	var retrieveError error
-	updatedCharacterInfo := make([]*model.CharacterInfo, 0)
+	updatedCharacterInfo := make([]*model.CharacterInfo, len(oldInfo))
 	// have to simulateneosly update all characters
-	for _, oldCharacter := range oldInfo {
+	for i, oldCharacter := range oldInfo {
 		wg.Add(1)
-		go func(region, realm, name string) {
+		go func(i int, old *model.CharacterInfo) {
 			defer wg.Done()
 			defer lock.Unlock()
 
-			character, err := s.bnetClient.GetCharacterProfile(region, realm, name)
+			// viewer is gone, no need to query the rest of the characters
+			if ctx.Err() != nil {
+				lock.Lock()
+				retrieveError = ctx.Err()
+				return
+			}
+			realm := s.realmSlug(ctx, old)
+			profile, _, cached, err := s.revalidateProfile(ctx, streamerID, old.Region, realm, old.Name)
+			if err == nil && !cached {
+				// list shows the same avatar as described profiles
+				s.describeMedia(ctx, old.Region, realm, old.Name, profile)
+			}
 			lock.Lock()
 			if err != nil {
 				retrieveError = err
 				return
 			}
-			profile := Convert(character)
-			charInfo := model.CharacterInfo{
-				CharIcon: profile.CharIcon,
-				Class:    profile.Class,
-				Name:     profile.Name,
-				Realm:    profile.Realm,
-				Region:   profile.Region,
-				Guild:    profile.Guild,
-				ItemLvl:  profile.ItemLvl,
-			}
-			updatedCharacterInfo = append(updatedCharacterInfo, &charInfo)
-		}(oldCharacter.Region, oldCharacter.Realm, oldCharacter.Name)
+			updatedCharacterInfo[i] = profile.Info()
+			updatedCharacterInfo[i].Order = old.Order
+		}(i, oldCharacter)
 	}
 	wg.Wait()
-	return updatedCharacterInfo, retrieveError
+	// characters that failed to download are left out
+	retrieved := updatedCharacterInfo[:0]
+	for _, info := range updatedCharacterInfo {
+		if info != nil {
+			retrieved = append(retrieved, info)
+		}
+	}
+	return retrieved, retrieveError
 }
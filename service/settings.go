@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/salmondx/wow-twitch-extension/model"
+)
+
+// Settings are read on every profile request, so they are kept in memory for a while.
+// Other instances see updated settings once their copy expires
+const settingsTTL = time.Minute
+
+type cachedSettings struct {
+	settings model.ChannelSettings
+	loadedAt time.Time
+}
+
+func (s *CachableCharacterService) Settings(ctx context.Context, streamerID string) (*model.ChannelSettings, error) {
+	if streamerID == "" {
+		return nil, model.ValidationError{"StreamerID can not be empty"}
+	}
+	s.settingsMu.Lock()
+	cached, ok := s.settings[streamerID]
+	s.settingsMu.Unlock()
+	if ok && time.Since(cached.loadedAt) < settingsTTL {
+		settings := cached.settings
+		return &settings, nil
+	}
+
+	settings, err := s.settingsStorage.GetSettings(ctx, streamerID)
+	if err != nil {
+		return nil, err
+	}
+	if settings.TooltipProvider == "" {
+		settings.TooltipProvider = DefaultTooltipProvider
+	}
+	s.cacheSettings(streamerID, settings)
+	return settings, nil
+}
+
+func (s *CachableCharacterService) UpdateSettings(ctx context.Context, streamerID string, settings *model.ChannelSettings) error {
+	if streamerID == "" || settings == nil {
+		return model.ValidationError{"StreamerID or settings can not be empty"}
+	}
+	if err := s.validateSettings(settings); err != nil {
+		return err
+	}
+	err := s.settingsStorage.SaveSettings(ctx, streamerID, settings)
+	if err != nil {
+		return err
+	}
+	s.cacheSettings(streamerID, settings)
+	return nil
+}
+
+// validateSettings checks settings, setting defaults of omitted ones
+func (s *CachableCharacterService) validateSettings(settings *model.ChannelSettings) error {
+	if settings.TooltipProvider == "" {
+		settings.TooltipProvider = DefaultTooltipProvider
+	}
+	if _, ok := s.tooltips[settings.TooltipProvider]; !ok {
+		return model.ValidationError{fmt.Sprintf("Unknown tooltip provider %s, available: %s", settings.TooltipProvider, strings.Join(s.tooltips.Names(), ", "))}
+	}
+	return nil
+}
+
+// cacheSettings keeps settings of the channel. Expired settings of other channels are dropped once in a while,
+// so only channels active within settingsTTL are kept in memory
+func (s *CachableCharacterService) cacheSettings(streamerID string, settings *model.ChannelSettings) {
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+	now := time.Now()
+	s.settings[streamerID] = cachedSettings{*settings, now}
+	if now.Sub(s.settingsSweptAt) < settingsTTL {
+		return
+	}
+	for id, cached := range s.settings {
+		if now.Sub(cached.loadedAt) >= settingsTTL {
+			delete(s.settings, id)
+		}
+	}
+	s.settingsSweptAt = now
+}
+
+// tooltipProvider returns provider selected by the channel. Profiles are still served with default provider
+// when settings are unavailable
+func (s *CachableCharacterService) tooltipProvider(ctx context.Context, streamerID string) string {
+	settings, err := s.Settings(ctx, streamerID)
+	if err != nil {
+		log.Printf("[WARN] Can't get settings of %s. %v", streamerID, err)
+		return DefaultTooltipProvider
+	}
+	return settings.TooltipProvider
+}
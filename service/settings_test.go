@@ -0,0 +1,21 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/salmondx/wow-twitch-extension/model"
+)
+
+func TestCacheSettingsSweep(t *testing.T) {
+	s := &CachableCharacterService{settings: map[string]cachedSettings{
+		"gone":   {loadedAt: time.Now().Add(-settingsTTL - time.Second)},
+		"active": {loadedAt: time.Now()},
+	}}
+
+	s.cacheSettings("streamer", &model.ChannelSettings{TooltipProvider: DefaultTooltipProvider})
+
+	if _, ok := s.settings["gone"]; ok || len(s.settings) != 2 {
+		t.Errorf("Only expired settings should be dropped, got %v", s.settings)
+	}
+}
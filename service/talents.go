@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/salmondx/wow-twitch-extension/bnet"
+	"github.com/salmondx/wow-twitch-extension/gamedata"
+	"github.com/salmondx/wow-twitch-extension/model"
+)
+
+// choiceNode is a node type of talent trees, where one of several talents is picked
+const choiceNode = "CHOICE"
+
+type nodeShape struct {
+	maxRank int
+	choice  bool
+}
+
+type talentTreeKey struct {
+	region model.Region
+	locale string
+	href   string
+}
+
+// TalentCatalog describes modern talent trees of characters. Tree layouts change only with game patches,
+// so every tree is retrieved once
+type TalentCatalog struct {
+	bnetClient *bnet.Client
+
+	mu    sync.Mutex
+	trees map[talentTreeKey]map[int]nodeShape
+}
+
+// NewTalentCatalog creates talent catalog. Without Battle.Net client profiles keep old talents only
+func NewTalentCatalog(bnetClient *bnet.Client) *TalentCatalog {
+	return &TalentCatalog{
+		bnetClient: bnetClient,
+		trees:      make(map[talentTreeKey]map[int]nodeShape),
+	}
+}
+
+// Describe adds class, spec and hero trees of active loadouts to profile specs. Realm is a slug.
+// Profile is left as is when loadouts can't be retrieved. Returns false if some trees may be described later
+func (c *TalentCatalog) Describe(ctx context.Context, region model.Region, realm, name, locale string, profile *model.Character) bool {
+	if c.bnetClient == nil {
+		return true
+	}
+	specializations, err := c.bnetClient.GetSpecializations(ctx, region, realm, name, locale)
+	if err != nil {
+		log.Printf("[WARN] Can't retrieve talent loadouts of %s - %s. %v", realm, name, err)
+		return isNotFound(err)
+	}
+	return c.describe(ctx, region, locale, specializations, profile)
+}
+
+func (c *TalentCatalog) describe(ctx context.Context, region model.Region, locale string, specializations *bnet.Specializations, profile *model.Character) bool {
+	complete := true
+	active := specializations.ActiveSpecialization.ID
+	if profile.SpecID == 0 {
+		if spec, ok := gamedata.SpecByID(active); ok {
+			profile.SpecID = spec.ID
+			profile.Role = string(spec.Role)
+		}
+	}
+	for _, bnetSpec := range specializations.Specializations {
+		loadout, ok := activeLoadout(bnetSpec.Loadouts)
+		if !ok {
+			continue
+		}
+		shapes, err := c.shapes(ctx, region, locale, loadout.SelectedSpecTalentTree)
+		if err != nil {
+			log.Printf("[WARN] Can't retrieve talent tree %s. %v", loadout.SelectedSpecTalentTree.Name, err)
+			complete = false
+		}
+
+		spec := findSpec(profile, bnetSpec.Specialization.ID, active, locale)
+		spec.LoadoutCode = loadout.TalentLoadoutCode
+		spec.ClassTree = talentTree(loadout.SelectedClassTalentTree, loadout.SelectedClassTalents, shapes)
+		spec.SpecTree = talentTree(loadout.SelectedSpecTalentTree, loadout.SelectedSpecTalents, shapes)
+		if len(loadout.SelectedHeroTalents) > 0 {
+			spec.HeroTree = talentTree(loadout.SelectedHeroTalentTree, loadout.SelectedHeroTalents, shapes)
+		}
+	}
+	return complete
+}
+
+// shapes returns max ranks and types of all nodes of a spec tree, including class and hero nodes
+func (c *TalentCatalog) shapes(ctx context.Context, region model.Region, locale string, tree bnet.Ref) (map[int]nodeShape, error) {
+	key := talentTreeKey{region, locale, tree.Key.Href}
+	c.mu.Lock()
+	shapes, ok := c.trees[key]
+	c.mu.Unlock()
+	if ok {
+		return shapes, nil
+	}
+
+	talentTree, err := c.bnetClient.GetTalentTree(ctx, region, tree, locale)
+	if err != nil {
+		return nil, err
+	}
+	shapes = make(map[int]nodeShape)
+	addShapes(shapes, talentTree.ClassTalentNodes)
+	addShapes(shapes, talentTree.SpecTalentNodes)
+	for _, hero := range talentTree.HeroTalentTrees {
+		addShapes(shapes, hero.HeroTalentNodes)
+	}
+	c.mu.Lock()
+	c.trees[key] = shapes
+	c.mu.Unlock()
+	return shapes, nil
+}
+
+func addShapes(shapes map[int]nodeShape, nodes []bnet.TalentTreeNode) {
+	for _, node := range nodes {
+		shapes[node.ID] = nodeShape{maxRank: len(node.Ranks), choice: node.NodeType.Type == choiceNode}
+	}
+}
+
+// activeLoadout returns active loadout of a spec, or the first one if spec is not active
+func activeLoadout(loadouts []bnet.Loadout) (bnet.Loadout, bool) {
+	for _, loadout := range loadouts {
+		if loadout.IsActive {
+			return loadout, true
+		}
+	}
+	if len(loadouts) == 0 {
+		return bnet.Loadout{}, false
+	}
+	return loadouts[0], true
+}
+
+// findSpec returns profile spec by id. Specs unknown to the old talents layout are added
+func findSpec(profile *model.Character, specID, active int, locale string) *model.Spec {
+	for i := range profile.Specs {
+		if profile.Specs[i].ID == specID {
+			return &profile.Specs[i]
+		}
+	}
+	spec := model.Spec{ID: specID, Selected: specID == active, Talents: make([]model.Talent, 0)}
+	if specData, ok := gamedata.SpecByID(specID); ok {
+		spec.Key = specData.Key
+		spec.Name = specData.Names.In(locale)
+		spec.Order = specData.Order
+	}
+	profile.Specs = append(profile.Specs, spec)
+	return &profile.Specs[len(profile.Specs)-1]
+}
+
+// talentTree converts selected nodes. Nodes missing in shapes keep their rank as max rank
+func talentTree(tree bnet.Ref, selected []bnet.SelectedTalent, shapes map[int]nodeShape) *model.TalentTree {
+	nodes := make([]model.TalentNode, 0, len(selected))
+	for _, talent := range selected {
+		shape, ok := shapes[talent.ID]
+		if !ok {
+			shape.maxRank = talent.Rank
+		}
+		nodes = append(nodes, model.TalentNode{
+			NodeID:   talent.ID,
+			TalentID: talent.Tooltip.Talent.ID,
+			Rank:     talent.Rank,
+			MaxRank:  shape.maxRank,
+			Choice:   shape.choice,
+			Spell: model.Spell{
+				ID:          talent.Tooltip.SpellTooltip.Spell.ID,
+				Name:        talent.Tooltip.SpellTooltip.Spell.Name,
+				Description: talent.Tooltip.SpellTooltip.Description,
+			},
+		})
+	}
+	return &model.TalentTree{ID: tree.ID, Name: tree.Name, Nodes: nodes}
+}
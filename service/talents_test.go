@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/salmondx/wow-twitch-extension/bnet"
+	"github.com/salmondx/wow-twitch-extension/model"
+)
+
+const armsTreeHref = "https://eu.api.blizzard.com/data/wow/talent-tree/780/playable-specialization/71?namespace=static-eu"
+
+const specializationsJSON = `{
+	"active_specialization": {"id": 72, "name": "Fury"},
+	"specializations": [
+		{
+			"specialization": {"id": 71, "name": "Arms"},
+			"loadouts": [
+				{"is_active": false, "talent_loadout_code": "BcGAAAA",
+				 "selected_spec_talent_tree": {"key": {"href": "` + armsTreeHref + `"}, "id": 71, "name": "Arms"},
+				 "selected_class_talent_tree": {"id": 780, "name": "Warrior"},
+				 "selected_class_talents": [
+					{"id": 90261, "rank": 1, "tooltip": {"talent": {"id": 112255}, "spell_tooltip": {"spell": {"id": 202163, "name": "Bounding Stride"}, "description": "Reduces cooldown"}}},
+					{"id": 90262, "rank": 1, "tooltip": {"talent": {"id": 112256}, "spell_tooltip": {"spell": {"id": 3411, "name": "Intervene"}}}}
+				 ],
+				 "selected_spec_talents": [
+					{"id": 90327, "rank": 2, "tooltip": {"talent": {"id": 112120}, "spell_tooltip": {"spell": {"id": 385512, "name": "Storm of Swords"}}}}
+				 ],
+				 "selected_hero_talent_tree": {"id": 33, "name": "Slayer"},
+				 "selected_hero_talents": [
+					{"id": 94971, "rank": 1, "tooltip": {"talent": {"id": 117411}, "spell_tooltip": {"spell": {"id": 444767, "name": "Slayer's Dominance"}}}}
+				 ]}
+			]
+		},
+		{"specialization": {"id": 72, "name": "Fury"}, "loadouts": []}
+	]
+}`
+
+func TestTalentCatalog(t *testing.T) {
+	var specializations bnet.Specializations
+	if err := json.Unmarshal([]byte(specializationsJSON), &specializations); err != nil {
+		t.Fatal(err)
+	}
+	catalog := NewTalentCatalog(nil)
+	catalog.trees[talentTreeKey{model.EU, "en_GB", armsTreeHref}] = map[int]nodeShape{
+		90261: {maxRank: 1},
+		90262: {maxRank: 1, choice: true},
+		90327: {maxRank: 2},
+	}
+	profile := &model.Character{
+		Specs: []model.Spec{{ID: 72, Key: "FURY", Name: "Fury", Selected: true}},
+	}
+
+	catalog.describe(context.Background(), model.EU, "en_GB", &specializations, profile)
+
+	if profile.SpecID != 72 || profile.Role != "damage" {
+		t.Errorf("Wrong active spec %d %s", profile.SpecID, profile.Role)
+	}
+	if len(profile.Specs) != 2 {
+		t.Fatalf("Expected spec without old talents to be added, got %v", profile.Specs)
+	}
+	if profile.Specs[0].SpecTree != nil {
+		t.Errorf("Spec without loadouts got talent tree")
+	}
+	arms := profile.Specs[1]
+	if arms.ID != 71 || arms.Key != "ARMS" || arms.Name != "Arms" || arms.Selected {
+		t.Errorf("Wrong added spec %v", arms)
+	}
+	if arms.LoadoutCode != "BcGAAAA" {
+		t.Errorf("Wrong loadout code %s", arms.LoadoutCode)
+	}
+	if arms.ClassTree == nil || arms.ClassTree.Name != "Warrior" || len(arms.ClassTree.Nodes) != 2 {
+		t.Fatalf("Wrong class tree %v", arms.ClassTree)
+	}
+	stride := arms.ClassTree.Nodes[0]
+	if stride.NodeID != 90261 || stride.TalentID != 112255 || stride.Spell.ID != 202163 || stride.Spell.Description != "Reduces cooldown" {
+		t.Errorf("Wrong node %v", stride)
+	}
+	if !arms.ClassTree.Nodes[1].Choice {
+		t.Errorf("Choice node is not marked")
+	}
+	if node := arms.SpecTree.Nodes[0]; node.Rank != 2 || node.MaxRank != 2 {
+		t.Errorf("Wrong ranks of %v", node)
+	}
+	// hero node is not part of cached tree, so its rank is the only one known
+	if arms.HeroTree == nil || arms.HeroTree.ID != 33 || arms.HeroTree.Nodes[0].MaxRank != 1 {
+		t.Errorf("Wrong hero tree %v", arms.HeroTree)
+	}
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/salmondx/wow-twitch-extension/model"
+)
+
+// TooltipProvider builds links to item and spell tooltips. Items carry gems, enchantment and bonus IDs,
+// so a tooltip shows the item exactly as equipped
+type TooltipProvider interface {
+	ItemURL(item model.Item) string
+	SpellURL(spell model.Spell) string
+}
+
+// Names of providers channels select in settings
+const (
+	Wowhead        = "wowhead"
+	WowheadClassic = "wowhead-classic"
+	SelfHosted     = "self-hosted"
+)
+
+// DefaultTooltipProvider is used by channels without settings
+const DefaultTooltipProvider = Wowhead
+
+// TooltipProviders are available providers by name
+type TooltipProviders map[string]TooltipProvider
+
+// NewTooltipProviders creates Wowhead providers. Self-hosted provider is available only with its base URL
+func NewTooltipProviders(selfHostedURL string) TooltipProviders {
+	providers := TooltipProviders{
+		Wowhead:        WowheadProvider{},
+		WowheadClassic: WowheadProvider{Domain: "classic"},
+	}
+	if selfHostedURL != "" {
+		providers[SelfHosted] = SelfHostedProvider{BaseURL: strings.TrimSuffix(selfHostedURL, "/")}
+	}
+	return providers
+}
+
+// Names returns sorted provider names
+func (p TooltipProviders) Names() []string {
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Link sets tooltip links of profile items and talents. Unknown provider falls back to default one
+func (p TooltipProviders) Link(name string, profile *model.Character) {
+	provider, ok := p[name]
+	if !ok {
+		provider, ok = p[DefaultTooltipProvider]
+		if !ok {
+			return
+		}
+	}
+	for i := range profile.Items {
+		profile.Items[i].DescriptionURL = provider.ItemURL(profile.Items[i])
+	}
+	for i := range profile.Specs {
+		spec := &profile.Specs[i]
+		for j := range spec.Talents {
+			spell := &spec.Talents[j].Spell
+			spell.DescriptionURL = provider.SpellURL(*spell)
+		}
+		for _, tree := range []*model.TalentTree{spec.ClassTree, spec.SpecTree, spec.HeroTree} {
+			if tree == nil {
+				continue
+			}
+			for j := range tree.Nodes {
+				spell := &tree.Nodes[j].Spell
+				spell.DescriptionURL = provider.SpellURL(*spell)
+			}
+		}
+	}
+}
+
+// WowheadProvider builds data-wowhead fragments like item=1&gems=2:3&ench=4&bonus=5:6.
+// Empty domain stands for retail
+type WowheadProvider struct {
+	Domain string
+}
+
+func (w WowheadProvider) ItemURL(item model.Item) string {
+	fragment := fmt.Sprintf("item=%d", item.ID)
+	if len(item.Gems) > 0 {
+		fragment += "&gems=" + joinIDs(item.Gems, ":")
+	}
+	if item.Enchantment != 0 {
+		fragment += fmt.Sprintf("&ench=%d", item.Enchantment)
+	}
+	if len(item.BonusIDs) > 0 {
+		fragment += "&bonus=" + joinIDs(item.BonusIDs, ":")
+	}
+	return w.withDomain(fragment)
+}
+
+func (w WowheadProvider) SpellURL(spell model.Spell) string {
+	return w.withDomain(fmt.Sprintf("spell=%d", spell.ID))
+}
+
+func (w WowheadProvider) withDomain(fragment string) string {
+	if w.Domain == "" {
+		return fragment
+	}
+	return fragment + "&domain=" + w.Domain
+}
+
+// SelfHostedProvider builds absolute links to a tooltip service, like BaseURL/item/1?gems=2,3&ench=4&bonus=5,6
+type SelfHostedProvider struct {
+	BaseURL string
+}
+
+func (s SelfHostedProvider) ItemURL(item model.Item) string {
+	var params []string
+	if len(item.Gems) > 0 {
+		params = append(params, "gems="+joinIDs(item.Gems, ","))
+	}
+	if item.Enchantment != 0 {
+		params = append(params, "ench="+strconv.Itoa(item.Enchantment))
+	}
+	if len(item.BonusIDs) > 0 {
+		params = append(params, "bonus="+joinIDs(item.BonusIDs, ","))
+	}
+	link := fmt.Sprintf("%s/item/%d", s.BaseURL, item.ID)
+	if len(params) > 0 {
+		link += "?" + strings.Join(params, "&")
+	}
+	return link
+}
+
+func (s SelfHostedProvider) SpellURL(spell model.Spell) string {
+	return fmt.Sprintf("%s/spell/%d", s.BaseURL, spell.ID)
+}
+
+func joinIDs(ids []int, separator string) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.Itoa(id)
+	}
+	return strings.Join(parts, separator)
+}
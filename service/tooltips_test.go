@@ -0,0 +1,59 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/salmondx/wow-twitch-extension/model"
+)
+
+func TestTooltipProviders(t *testing.T) {
+	providers := NewTooltipProviders("https://tooltips.example.com/")
+	item := model.Item{ID: 133767, Gems: []int{1235, 12445}, Enchantment: 123567, BonusIDs: []int{6652, 7981}}
+	plain := model.Item{ID: 133767}
+	spell := model.Spell{ID: 107570}
+
+	var tests = []struct {
+		provider string
+		item     string
+		plain    string
+		spell    string
+	}{
+		{Wowhead, "item=133767&gems=1235:12445&ench=123567&bonus=6652:7981", "item=133767", "spell=107570"},
+		{WowheadClassic, "item=133767&gems=1235:12445&ench=123567&bonus=6652:7981&domain=classic", "item=133767&domain=classic", "spell=107570&domain=classic"},
+		{SelfHosted, "https://tooltips.example.com/item/133767?gems=1235,12445&ench=123567&bonus=6652,7981", "https://tooltips.example.com/item/133767", "https://tooltips.example.com/spell/107570"},
+	}
+	for _, tt := range tests {
+		provider := providers[tt.provider]
+		if link := provider.ItemURL(item); link != tt.item {
+			t.Errorf("%s item link %s, expected %s", tt.provider, link, tt.item)
+		}
+		if link := provider.ItemURL(plain); link != tt.plain {
+			t.Errorf("%s item link %s, expected %s", tt.provider, link, tt.plain)
+		}
+		if link := provider.SpellURL(spell); link != tt.spell {
+			t.Errorf("%s spell link %s, expected %s", tt.provider, link, tt.spell)
+		}
+	}
+
+	if _, ok := NewTooltipProviders("")[SelfHosted]; ok {
+		t.Errorf("Self-hosted provider without URL")
+	}
+}
+
+func TestTooltipLink(t *testing.T) {
+	profile := &model.Character{
+		Items: []model.Item{{ID: 1}},
+		Specs: []model.Spec{{Talents: []model.Talent{{Spell: model.Spell{ID: 2}}}}},
+	}
+	providers := NewTooltipProviders("")
+
+	providers.Link(WowheadClassic, profile)
+	if profile.Items[0].DescriptionURL != "item=1&domain=classic" || profile.Specs[0].Talents[0].Spell.DescriptionURL != "spell=2&domain=classic" {
+		t.Errorf("Links are not set: %s, %s", profile.Items[0].DescriptionURL, profile.Specs[0].Talents[0].Spell.DescriptionURL)
+	}
+	// unavailable provider falls back to default
+	providers.Link(SelfHosted, profile)
+	if profile.Items[0].DescriptionURL != "item=1" {
+		t.Errorf("Default provider is not used: %s", profile.Items[0].DescriptionURL)
+	}
+}
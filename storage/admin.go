@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Streamers returns ids of all streamers with saved characters
+func (db *DynamoRepository) Streamers(ctx context.Context) ([]string, error) {
+	seen := make(map[string]bool)
+	input := &dynamodb.ScanInput{
+		TableName:            aws.String(characterTable),
+		ProjectionExpression: aws.String("streamerID"),
+	}
+	for {
+		resp, err := db.client.ScanWithContext(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("Can not scan characters. Reason: %v", err)
+		}
+		for _, item := range resp.Items {
+			if id := item["streamerID"]; id != nil && id.S != nil {
+				seen[*id.S] = true
+			}
+		}
+		if len(resp.LastEvaluatedKey) == 0 {
+			break
+		}
+		input.ExclusiveStartKey = resp.LastEvaluatedKey
+	}
+	streamers := make([]string, 0, len(seen))
+	for streamerID := range seen {
+		streamers = append(streamers, streamerID)
+	}
+	sort.Strings(streamers)
+	return streamers, nil
+}
+
+// CharacterCount returns number of stored characters and value of the counter enforcing the limit.
+// Counter is -1 if it's not created yet
+func (db *DynamoRepository) CharacterCount(ctx context.Context, streamerID string) (stored, counter int, err error) {
+	if streamerID == "" {
+		return 0, 0, errors.New("streamerID can not be empty")
+	}
+	stored, err = db.count(ctx, streamerID)
+	if err != nil {
+		return 0, 0, err
+	}
+	resp, err := db.client.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		Key:            streamerKey(streamerID),
+		TableName:      aws.String(characterCountTable),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("Can not get character count of %s. Reason: %v", streamerID, err)
+	}
+	if resp == nil || resp.Item["count"] == nil || resp.Item["count"].N == nil {
+		return stored, -1, nil
+	}
+	counter, err = strconv.Atoi(*resp.Item["count"].N)
+	if err != nil {
+		return 0, 0, fmt.Errorf("Malformed character count of %s: %v", streamerID, err)
+	}
+	return stored, counter, nil
+}
+
+// Recount resets the counter enforcing the limit to the number of stored characters
+func (db *DynamoRepository) Recount(ctx context.Context, streamerID string) error {
+	if streamerID == "" {
+		return errors.New("streamerID can not be empty")
+	}
+	return db.recount(ctx, streamerID)
+}
+
+// DeleteChannel deletes all characters, the counter and settings of a streamer.
+// Characters are deleted by their saved ids, so ids of any format are removed. Returns number of deleted characters
+func (db *DynamoRepository) DeleteChannel(ctx context.Context, streamerID string) (int, error) {
+	if streamerID == "" {
+		return 0, errors.New("streamerID can not be empty")
+	}
+	resp, err := db.client.QueryWithContext(ctx, selectAllQuery(streamerID))
+	if err != nil {
+		return 0, fmt.Errorf("Can not get characters for %s, reason: %v", streamerID, err)
+	}
+	requests := make([]*dynamodb.WriteRequest, 0, len(resp.Items))
+	for _, item := range resp.Items {
+		key := map[string]*dynamodb.AttributeValue{
+			"streamerID":  item["streamerID"],
+			"characterID": item["characterID"],
+		}
+		requests = append(requests, &dynamodb.WriteRequest{DeleteRequest: &dynamodb.DeleteRequest{Key: key}})
+	}
+	if err := db.batchWrite(ctx, requests); err != nil {
+		// some characters may be deleted already, so the counter must follow the table
+		if countErr := db.recount(ctx, streamerID); countErr != nil {
+			log.Printf("[ERROR] %v", countErr)
+		}
+		return 0, err
+	}
+
+	// the counter is deleted last, when no character is left to be counted
+	for _, table := range []string{settingsTable, characterCountTable} {
+		_, err = db.client.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+			Key:       streamerKey(streamerID),
+			TableName: aws.String(table),
+		})
+		if err != nil {
+			return len(requests), fmt.Errorf("Can not delete %s of %s. Reason: %v", table, streamerID, err)
+		}
+	}
+	return len(requests), nil
+}
@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// characterCountTable keeps number of characters of every streamer. The counter is updated in the same transaction
+// as characters, so its condition enforces the character limit for concurrent writes, which a query of the character table can't do
+const characterCountTable = "STREAMER_CHARACTER_COUNTS"
+
+// countUpdate changes character count of a streamer by n as a part of a transaction.
+// Additions are rejected when the count would exceed the limit
+func countUpdate(streamerID string, n int) *dynamodb.TransactWriteItem {
+	update := &dynamodb.Update{
+		Key:                      streamerKey(streamerID),
+		TableName:                aws.String(characterCountTable),
+		UpdateExpression:         aws.String("ADD #count :n"),
+		ExpressionAttributeNames: map[string]*string{"#count": aws.String("count")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":n": {N: aws.String(strconv.Itoa(n))},
+		},
+	}
+	if n > 0 {
+		update.ConditionExpression = aws.String("#count <= :bound")
+		update.ExpressionAttributeValues[":bound"] = &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(CharacterLimit - n))}
+	}
+	return &dynamodb.TransactWriteItem{Update: update}
+}
+
+// initCount creates counter of streamers who saved characters before counters were introduced
+func (db *DynamoRepository) initCount(ctx context.Context, streamerID string) error {
+	resp, err := db.client.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		Key:            streamerKey(streamerID),
+		TableName:      aws.String(characterCountTable),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return fmt.Errorf("Can not get character count of %s. Reason: %v", streamerID, err)
+	}
+	if resp != nil && len(resp.Item) > 0 {
+		return nil
+	}
+	count, err := db.count(ctx, streamerID)
+	if err != nil {
+		return err
+	}
+	// a concurrent request may have created the counter already
+	err = db.putCount(ctx, streamerID, count, aws.String("attribute_not_exists(streamerID)"))
+	if isConditionFailed(err) {
+		return nil
+	}
+	return err
+}
+
+// recount resets counter to the number of stored characters
+func (db *DynamoRepository) recount(ctx context.Context, streamerID string) error {
+	count, err := db.count(ctx, streamerID)
+	if err != nil {
+		return err
+	}
+	return db.putCount(ctx, streamerID, count, nil)
+}
+
+func (db *DynamoRepository) count(ctx context.Context, streamerID string) (int, error) {
+	query := selectAllQuery(streamerID)
+	query.SetSelect("COUNT")
+	resp, err := db.client.QueryWithContext(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("Can not count characters for %s. Reason: %v", streamerID, err)
+	}
+	return int(aws.Int64Value(resp.Count)), nil
+}
+
+func (db *DynamoRepository) putCount(ctx context.Context, streamerID string, count int, condition *string) error {
+	item := streamerKey(streamerID)
+	item["count"] = &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(count))}
+	_, err := db.client.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		Item:                item,
+		TableName:           aws.String(characterCountTable),
+		ConditionExpression: condition,
+	})
+	if err != nil && !isConditionFailed(err) {
+		return fmt.Errorf("Can not save character count of %s. Reason: %v", streamerID, err)
+	}
+	return err
+}
+
+// streamerKey is a key of tables with one item per streamer
+func streamerKey(streamerID string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"streamerID": {
+			S: aws.String(streamerID),
+		},
+	}
+}
+
+func isConditionFailed(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException
+}
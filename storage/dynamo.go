@@ -1,8 +1,12 @@
 package storage
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"log"
+	"sort"
+	"time"
 
 	"github.com/salmondx/wow-twitch-extension/model"
 
@@ -11,10 +15,22 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 )
 
 const characterTable = "STREAMER_CHARACTERS"
-const characterLimit = 20
+
+// CharacterLimit is a maximum number of characters of a streamer
+const CharacterLimit = 20
+
+// maxBatchSize is a limit of DynamoDB BatchWriteItem
+const maxBatchSize = 25
+
+// unprocessed items of a batch are retried with growing delay
+const (
+	batchAttempts = 3
+	batchBackoff  = 100 * time.Millisecond
+)
 
 type CharacterInfoItem struct {
 	*model.CharacterInfo
@@ -24,7 +40,7 @@ type CharacterInfoItem struct {
 
 // DynamoRepository is a CharacterRepository implementation for DynamoDB
 type DynamoRepository struct {
-	client *dynamodb.DynamoDB
+	client dynamodbiface.DynamoDBAPI
 }
 
 func New() (*DynamoRepository, error) {
@@ -38,13 +54,13 @@ func New() (*DynamoRepository, error) {
 	return &DynamoRepository{client}, nil
 }
 
-func (db *DynamoRepository) List(streamerID string) ([]*model.CharacterInfo, error) {
+func (db *DynamoRepository) List(ctx context.Context, streamerID string) ([]*model.CharacterInfo, error) {
 	if streamerID == "" {
 		return nil, errors.New("streamerID can not be empty")
 	}
 
 	query := selectAllQuery(streamerID)
-	resp, err := db.client.Query(query)
+	resp, err := db.client.QueryWithContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("Can not get characters for %s, reason: %v", streamerID, err)
 	}
@@ -61,78 +77,214 @@ func (db *DynamoRepository) List(streamerID string) ([]*model.CharacterInfo, err
 
 		characterInfos[i] = characterItem.CharacterInfo
 	}
+	// query returns characters by id
+	sort.SliceStable(characterInfos, func(i, j int) bool {
+		return characterInfos[i].Order < characterInfos[j].Order
+	})
 	return characterInfos, nil
 }
 
-func (db *DynamoRepository) Add(streamerID string, character *model.CharacterInfo) error {
+func (db *DynamoRepository) Add(ctx context.Context, streamerID string, character *model.CharacterInfo) error {
 	if streamerID == "" || character == nil {
 		return errors.New("StreamerID or character info can not be empty")
 	}
-
-	query := selectAllQuery(streamerID)
-	query.SetSelect("COUNT")
-
-	resp, err := db.client.Query(query)
+	failed, err := db.AddMany(ctx, streamerID, []*model.CharacterInfo{character})
 	if err != nil {
-		return fmt.Errorf("Can not count characters for %s. Reason: %v", streamerID, err)
+		return err
+	}
+	return failed[0]
+}
+
+func (db *DynamoRepository) AddMany(ctx context.Context, streamerID string, characters []*model.CharacterInfo) ([]error, error) {
+	if streamerID == "" {
+		return nil, errors.New("StreamerID can not be empty")
 	}
-	count := resp.Count
-	if *count >= characterLimit {
-		return model.CharacterLimitError{fmt.Sprintf("Can't add character for %s. Limit is 20.", streamerID)}
+	if len(characters) > CharacterLimit {
+		return nil, model.CharacterLimitError{fmt.Sprintf("Can't add %d characters for %s. Limit is %d.", len(characters), streamerID, CharacterLimit)}
 	}
 
-	characterItem := CharacterInfoItem{
-		CharacterInfo: character,
-		CharacterID:   createCharacterID(character),
-		StreamerID:    streamerID,
+	now := time.Now().UnixNano()
+	items := make([]*dynamodb.TransactWriteItem, len(characters))
+	for i, character := range characters {
+		info := *character
+		if info.Order == 0 {
+			info.Order = now + int64(i)
+		}
+		characterItem := CharacterInfoItem{
+			CharacterInfo: &info,
+			CharacterID:   createCharacterID(character),
+			StreamerID:    streamerID,
+		}
+		item, err := dynamodbattribute.MarshalMap(characterItem)
+		if err != nil {
+			return nil, fmt.Errorf("Can not marshal character item: %v. Reason: %v", characterItem, err)
+		}
+		items[i] = &dynamodb.TransactWriteItem{Put: &dynamodb.Put{
+			Item:                item,
+			TableName:           aws.String(characterTable),
+			ConditionExpression: aws.String("attribute_not_exists(characterID)"),
+		}}
 	}
+	return db.transactCharacters(ctx, streamerID, items, 1, func(i int) error {
+		return model.CharacterDuplicateError{fmt.Sprintf("Character with name %s on realm %s already exists", characters[i].Name, characters[i].Realm)}
+	})
+}
 
-	req, err := dynamodbattribute.MarshalMap(characterItem)
-	if err != nil {
-		return fmt.Errorf("Can not marshal character item: %v. Reason: %v", characterItem, err)
+// transactCharacters writes characters together with their count in a single transaction, so the counter never drifts
+// from stored characters. sign is 1 for puts and -1 for deletes, conflict describes a character whose condition failed.
+// Such characters are left out and the rest is written again. Returned errors are conflicts by character
+func (db *DynamoRepository) transactCharacters(ctx context.Context, streamerID string, items []*dynamodb.TransactWriteItem,
+	sign int, conflict func(i int) error) ([]error, error) {
+	failed := make([]error, len(items))
+	pending := make([]int, 0, len(items))
+	ids := make(map[string]bool, len(items))
+	for i, item := range items {
+		// a transaction can't touch the same item twice
+		id := aws.StringValue(characterItemKey(item)["characterID"].S)
+		if ids[id] {
+			failed[i] = conflict(i)
+			continue
+		}
+		ids[id] = true
+		pending = append(pending, i)
+	}
+	if len(pending) == 0 {
+		return failed, nil
+	}
+	if err := db.initCount(ctx, streamerID); err != nil {
+		return nil, err
 	}
 
-	_, err = db.client.PutItem(&dynamodb.PutItemInput{
-		Item:      req,
-		TableName: aws.String(characterTable),
-	})
-	if err != nil {
-		return fmt.Errorf("Can not insert item into db. Reason: %v", err)
+	for attempt := 0; len(pending) > 0; {
+		transaction := make([]*dynamodb.TransactWriteItem, 0, len(pending)+1)
+		for _, i := range pending {
+			transaction = append(transaction, items[i])
+		}
+		transaction = append(transaction, countUpdate(streamerID, sign*len(pending)))
+		_, err := db.client.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: transaction})
+		if err == nil {
+			return failed, nil
+		}
+		canceled, ok := err.(*dynamodb.TransactionCanceledException)
+		if !ok || len(canceled.CancellationReasons) != len(transaction) {
+			return nil, fmt.Errorf("Can not write characters of %s. Reason: %v", streamerID, err)
+		}
+
+		remaining := make([]int, 0, len(pending))
+		for k, i := range pending {
+			if conditionFailed(canceled.CancellationReasons[k]) {
+				failed[i] = conflict(i)
+				continue
+			}
+			remaining = append(remaining, i)
+		}
+		if len(remaining) < len(pending) {
+			pending = remaining
+			continue
+		}
+		if conditionFailed(canceled.CancellationReasons[len(pending)]) {
+			return failed, model.CharacterLimitError{fmt.Sprintf("Can't add %d characters for %s. Limit is %d.", len(pending), streamerID, CharacterLimit)}
+		}
+		// canceled by a concurrent transaction of the same items
+		attempt++
+		if attempt == batchAttempts {
+			return nil, fmt.Errorf("Can not write characters of %s. Reason: %v", streamerID, err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(batchBackoff << uint(attempt-1)):
+		}
+	}
+	return failed, nil
+}
+
+func characterItemKey(item *dynamodb.TransactWriteItem) map[string]*dynamodb.AttributeValue {
+	if item.Put != nil {
+		return item.Put.Item
 	}
+	return item.Delete.Key
+}
+
+func conditionFailed(reason *dynamodb.CancellationReason) bool {
+	return reason != nil && aws.StringValue(reason.Code) == "ConditionalCheckFailed"
+}
 
+// batchWrite writes requests in batches. Items DynamoDB couldn't process are retried
+func (db *DynamoRepository) batchWrite(ctx context.Context, requests []*dynamodb.WriteRequest) error {
+	for start := 0; start < len(requests); start += maxBatchSize {
+		end := start + maxBatchSize
+		if end > len(requests) {
+			end = len(requests)
+		}
+		pending := requests[start:end]
+		for attempt := 0; len(pending) > 0; attempt++ {
+			if attempt == batchAttempts {
+				return fmt.Errorf("Can not write %d items into db. Throughput exceeded", len(pending))
+			}
+			if attempt > 0 {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(batchBackoff << uint(attempt-1)):
+				}
+			}
+			resp, err := db.client.BatchWriteItemWithContext(ctx, &dynamodb.BatchWriteItemInput{
+				RequestItems: map[string][]*dynamodb.WriteRequest{characterTable: pending},
+			})
+			if err != nil {
+				return fmt.Errorf("Can not write items into db. Reason: %v", err)
+			}
+			pending = nil
+			if resp != nil {
+				pending = resp.UnprocessedItems[characterTable]
+			}
+		}
+	}
 	return nil
 }
 
-func (db *DynamoRepository) Delete(streamerID, region, realm, name string) error {
+func (db *DynamoRepository) Delete(ctx context.Context, streamerID string, region model.Region, realm, name string) error {
 	if streamerID == "" || realm == "" || name == "" {
 		return errors.New("StreamerID, realm or name can not be empty")
 	}
+	// deleting a missing character is not an error
+	_, err := db.DeleteMany(ctx, streamerID, []model.CharacterRef{{Region: region, Realm: realm, Name: name}})
+	return err
+}
 
-	query := &dynamodb.DeleteItemInput{
-		Key: map[string]*dynamodb.AttributeValue{
-			"streamerID": {
-				S: aws.String(streamerID),
-			},
-			"characterID": {
-				S: aws.String(genCharacterID(region, realm, name)),
-			},
-		},
-		TableName: aws.String(characterTable),
+func (db *DynamoRepository) DeleteMany(ctx context.Context, streamerID string, characters []model.CharacterRef) ([]error, error) {
+	if streamerID == "" {
+		return nil, errors.New("StreamerID can not be empty")
+	}
+	if len(characters) > CharacterLimit {
+		return nil, fmt.Errorf("Can not delete %d characters at once. Limit is %d", len(characters), CharacterLimit)
 	}
 
-	_, err := db.client.DeleteItem(query)
-	if err != nil {
-		return fmt.Errorf("Can't delete character %s on realm %s of streamer %s. Reason: %v", name, realm, streamerID, err)
+	items := make([]*dynamodb.TransactWriteItem, len(characters))
+	for i, character := range characters {
+		if character.Realm == "" || character.Name == "" {
+			return nil, errors.New("Realm or name can not be empty")
+		}
+		key := characterKey(streamerID, genCharacterID(character.Region, character.Realm, character.Name)).Key
+		items[i] = &dynamodb.TransactWriteItem{Delete: &dynamodb.Delete{
+			Key:                 key,
+			TableName:           aws.String(characterTable),
+			ConditionExpression: aws.String("attribute_exists(characterID)"),
+		}}
 	}
-	return nil
+	return db.transactCharacters(ctx, streamerID, items, -1, func(i int) error {
+		return model.CharacterNotFound{fmt.Sprintf("Character %s on realm %s is not added", characters[i].Name, characters[i].Realm)}
+	})
 }
 
 func createCharacterID(character *model.CharacterInfo) string {
-	return genCharacterID(character.Region, character.Realm, character.Name)
+	return genCharacterID(character.Region, character.RealmSlug, character.Name)
 }
 
-func genCharacterID(region, realm, name string) string {
-	return region + ":" + realm + ":" + name
+// genCharacterID expects realm slug
+func genCharacterID(region model.Region, realm, name string) string {
+	return model.CharacterID(region, realm, name)
 }
 
 func selectAllQuery(streamerID string) *dynamodb.QueryInput {
@@ -150,3 +302,95 @@ func selectAllQuery(streamerID string) *dynamodb.QueryInput {
 		},
 	}
 }
+
+// MigrateCharacterIDs rewrites characters saved with ids built from raw realm and character names.
+// resolve returns slug of a realm name. A character whose new id is already taken is kept under its old id
+// and reported as a collision. Returns numbers of migrated and collided characters
+func (db *DynamoRepository) MigrateCharacterIDs(ctx context.Context, resolve func(ctx context.Context, region model.Region, realm string) (string, error)) (int, int, error) {
+	migrated, collided := 0, 0
+	streamers := make(map[string]bool)
+	// counters are rebuilt from the table, however the migration ends
+	defer func() {
+		for streamerID := range streamers {
+			if countErr := db.recount(ctx, streamerID); countErr != nil {
+				log.Printf("[ERROR] %v", countErr)
+			}
+		}
+	}()
+
+	input := &dynamodb.ScanInput{TableName: aws.String(characterTable)}
+	for {
+		resp, err := db.client.ScanWithContext(ctx, input)
+		if err != nil {
+			return migrated, collided, fmt.Errorf("Can not scan characters. Reason: %v", err)
+		}
+		for _, item := range resp.Items {
+			characterItem := &CharacterInfoItem{}
+			err = dynamodbattribute.UnmarshalMap(item, characterItem)
+			if err != nil {
+				return migrated, collided, fmt.Errorf("Can not unmarshal result: %v", err)
+			}
+			if characterItem.CharacterInfo == nil || characterItem.RealmSlug != "" {
+				continue
+			}
+
+			slug, err := resolve(ctx, characterItem.Region, characterItem.Realm)
+			if err != nil {
+				log.Printf("[WARN] Can't migrate %s of %s: %v", characterItem.CharacterID, characterItem.StreamerID, err)
+				continue
+			}
+			oldID := characterItem.CharacterID
+			characterItem.RealmSlug = slug
+			characterItem.CharacterID = createCharacterID(characterItem.CharacterInfo)
+
+			req, err := dynamodbattribute.MarshalMap(characterItem)
+			if err != nil {
+				return migrated, collided, fmt.Errorf("Can not marshal character item: %v. Reason: %v", characterItem, err)
+			}
+			put := &dynamodb.PutItemInput{
+				Item:      req,
+				TableName: aws.String(characterTable),
+			}
+			if oldID != characterItem.CharacterID {
+				// the same character saved under another spelling must not be overwritten
+				put.ConditionExpression = aws.String("attribute_not_exists(characterID)")
+			}
+			_, err = db.client.PutItemWithContext(ctx, put)
+			if isConditionFailed(err) {
+				log.Printf("[WARN] Can't migrate %s of %s: %s is already saved", oldID, characterItem.StreamerID, characterItem.CharacterID)
+				streamers[characterItem.StreamerID] = true
+				collided++
+				continue
+			}
+			if err != nil {
+				return migrated, collided, fmt.Errorf("Can not insert item into db. Reason: %v", err)
+			}
+			streamers[characterItem.StreamerID] = true
+			if oldID != characterItem.CharacterID {
+				_, err = db.client.DeleteItemWithContext(ctx, characterKey(characterItem.StreamerID, oldID))
+				if err != nil {
+					return migrated, collided, fmt.Errorf("Can not delete %s of %s. Reason: %v", oldID, characterItem.StreamerID, err)
+				}
+			}
+			migrated++
+		}
+		if len(resp.LastEvaluatedKey) == 0 {
+			return migrated, collided, nil
+		}
+		input.ExclusiveStartKey = resp.LastEvaluatedKey
+	}
+}
+
+func characterKey(streamerID, characterID string) *dynamodb.DeleteItemInput {
+	return &dynamodb.DeleteItemInput{
+		Key: map[string]*dynamodb.AttributeValue{
+			"streamerID": {
+				S: aws.String(streamerID),
+			},
+			"characterID": {
+				S: aws.String(characterID),
+			},
+		},
+		TableName: aws.String(characterTable),
+	}
+}
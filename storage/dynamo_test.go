@@ -0,0 +1,258 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+
+	"github.com/salmondx/wow-twitch-extension/model"
+)
+
+// memoryDynamo keeps characters and counters of a single streamer. It evaluates only conditions used by the repository
+type memoryDynamo struct {
+	dynamodbiface.DynamoDBAPI
+	characters map[string]bool
+	count      *int
+	// items are put characters by id
+	items map[string]map[string]*dynamodb.AttributeValue
+}
+
+func (d *memoryDynamo) QueryWithContext(ctx aws.Context, input *dynamodb.QueryInput, opts ...request.Option) (*dynamodb.QueryOutput, error) {
+	if aws.StringValue(input.Select) == "COUNT" {
+		return &dynamodb.QueryOutput{Count: aws.Int64(int64(len(d.characters)))}, nil
+	}
+	// query returns characters by id
+	ids := make([]string, 0, len(d.items))
+	for id := range d.items {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	resp := &dynamodb.QueryOutput{}
+	for _, id := range ids {
+		resp.Items = append(resp.Items, d.items[id])
+	}
+	return resp, nil
+}
+
+func (d *memoryDynamo) GetItemWithContext(ctx aws.Context, input *dynamodb.GetItemInput, opts ...request.Option) (*dynamodb.GetItemOutput, error) {
+	if d.count == nil {
+		return &dynamodb.GetItemOutput{}, nil
+	}
+	item := streamerKey(aws.StringValue(input.Key["streamerID"].S))
+	item["count"] = &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(*d.count))}
+	return &dynamodb.GetItemOutput{Item: item}, nil
+}
+
+func (d *memoryDynamo) PutItemWithContext(ctx aws.Context, input *dynamodb.PutItemInput, opts ...request.Option) (*dynamodb.PutItemOutput, error) {
+	if aws.StringValue(input.TableName) == characterTable {
+		id := aws.StringValue(input.Item["characterID"].S)
+		if input.ConditionExpression != nil && d.characters[id] {
+			return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "exists", nil)
+		}
+		d.characters[id] = true
+		d.items[id] = input.Item
+		return &dynamodb.PutItemOutput{}, nil
+	}
+	count, _ := strconv.Atoi(aws.StringValue(input.Item["count"].N))
+	d.count = &count
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (d *memoryDynamo) DeleteItemWithContext(ctx aws.Context, input *dynamodb.DeleteItemInput, opts ...request.Option) (*dynamodb.DeleteItemOutput, error) {
+	delete(d.characters, aws.StringValue(input.Key["characterID"].S))
+	delete(d.items, aws.StringValue(input.Key["characterID"].S))
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+func (d *memoryDynamo) ScanWithContext(ctx aws.Context, input *dynamodb.ScanInput, opts ...request.Option) (*dynamodb.ScanOutput, error) {
+	resp, err := d.QueryWithContext(ctx, &dynamodb.QueryInput{})
+	return &dynamodb.ScanOutput{Items: resp.Items}, err
+}
+
+func (d *memoryDynamo) TransactWriteItemsWithContext(ctx aws.Context, input *dynamodb.TransactWriteItemsInput, opts ...request.Option) (*dynamodb.TransactWriteItemsOutput, error) {
+	reasons := make([]*dynamodb.CancellationReason, len(input.TransactItems))
+	canceled := false
+	added := 0
+	for i, item := range input.TransactItems {
+		code := "None"
+		switch {
+		case item.Put != nil:
+			if d.characters[aws.StringValue(item.Put.Item["characterID"].S)] {
+				code = "ConditionalCheckFailed"
+			}
+			added++
+		case item.Delete != nil:
+			if !d.characters[aws.StringValue(item.Delete.Key["characterID"].S)] {
+				code = "ConditionalCheckFailed"
+			}
+			added--
+		case item.Update != nil:
+			bound := item.Update.ExpressionAttributeValues[":bound"]
+			if bound != nil {
+				limit, _ := strconv.Atoi(aws.StringValue(bound.N))
+				if *d.count > limit {
+					code = "ConditionalCheckFailed"
+				}
+			}
+		}
+		canceled = canceled || code != "None"
+		reasons[i] = &dynamodb.CancellationReason{Code: aws.String(code)}
+	}
+	if canceled {
+		return nil, &dynamodb.TransactionCanceledException{Message_: aws.String("canceled"), CancellationReasons: reasons}
+	}
+	for _, item := range input.TransactItems {
+		switch {
+		case item.Put != nil:
+			id := aws.StringValue(item.Put.Item["characterID"].S)
+			d.characters[id] = true
+			if d.items != nil {
+				d.items[id] = item.Put.Item
+			}
+		case item.Delete != nil:
+			delete(d.characters, aws.StringValue(item.Delete.Key["characterID"].S))
+			delete(d.items, aws.StringValue(item.Delete.Key["characterID"].S))
+		}
+	}
+	*d.count += added
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
+func character(name string) *model.CharacterInfo {
+	return &model.CharacterInfo{Region: model.EU, Realm: "Soulflayer", RealmSlug: "soulflayer", Name: name}
+}
+
+func TestAddManyKeepsCounter(t *testing.T) {
+	// characters saved before counters were introduced
+	client := &memoryDynamo{characters: map[string]bool{"eu:soulflayer:old": true}}
+	db := &DynamoRepository{client: client}
+	ctx := context.Background()
+
+	failed, err := db.AddMany(ctx, "streamer", []*model.CharacterInfo{character("Salmond"), character("Old"), character("salmond")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed[0] != nil || failed[1] == nil || failed[2] == nil {
+		t.Errorf("Expected duplicates to fail only, got %v", failed)
+	}
+	if len(client.characters) != 2 || *client.count != 2 {
+		t.Errorf("Counter %d doesn't match %d characters", *client.count, len(client.characters))
+	}
+
+	failed, err = db.DeleteMany(ctx, "streamer", []model.CharacterRef{
+		{Region: model.EU, Realm: "soulflayer", Name: "Salmond"},
+		{Region: model.EU, Realm: "soulflayer", Name: "Missing"},
+		{Region: model.EU, Realm: "soulflayer", Name: "Salmond"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := failed[1].(model.CharacterNotFound); !ok || failed[0] != nil || failed[2] == nil {
+		t.Errorf("Expected missing characters to fail only, got %v", failed)
+	}
+	if len(client.characters) != 1 || *client.count != 1 {
+		t.Errorf("Counter %d doesn't match %d characters", *client.count, len(client.characters))
+	}
+}
+
+func TestAddManyLimit(t *testing.T) {
+	client := &memoryDynamo{characters: map[string]bool{}}
+	db := &DynamoRepository{client: client}
+	ctx := context.Background()
+
+	characters := make([]*model.CharacterInfo, 0, CharacterLimit)
+	for i := 0; i < CharacterLimit-1; i++ {
+		characters = append(characters, character("Alt"+strconv.Itoa(i)))
+	}
+	if _, err := db.AddMany(ctx, "streamer", characters); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := db.AddMany(ctx, "streamer", []*model.CharacterInfo{character("Salmond"), character("Salmondh")})
+	if _, ok := err.(model.CharacterLimitError); !ok {
+		t.Errorf("Expected limit error, got %v", err)
+	}
+	if *client.count != CharacterLimit-1 {
+		t.Errorf("Rejected characters are counted: %d", *client.count)
+	}
+	// a duplicate doesn't take space of the last free slot
+	failed, err := db.AddMany(ctx, "streamer", []*model.CharacterInfo{character("Alt0"), character("Salmond")})
+	if err != nil || failed[0] == nil || failed[1] != nil {
+		t.Errorf("Expected duplicate to fail only, got %v, %v", failed, err)
+	}
+	if *client.count != CharacterLimit || len(client.characters) != CharacterLimit {
+		t.Errorf("Counter %d doesn't match %d characters", *client.count, len(client.characters))
+	}
+}
+
+func TestListOrder(t *testing.T) {
+	client := &memoryDynamo{characters: map[string]bool{}, items: map[string]map[string]*dynamodb.AttributeValue{}}
+	db := &DynamoRepository{client: client}
+	ctx := context.Background()
+
+	exported := character("Alt")
+	exported.Order = 1
+	for _, characters := range [][]*model.CharacterInfo{
+		{character("Salmond"), character("Bank")},
+		{character("Mage"), exported},
+	} {
+		if _, err := db.AddMany(ctx, "streamer", characters); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	characters, err := db.List(ctx, "streamer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(characters))
+	for _, character := range characters {
+		names = append(names, character.Name)
+	}
+	if strings.Join(names, ",") != "Alt,Salmond,Bank,Mage" {
+		t.Errorf("Characters are not listed in order: %v", names)
+	}
+}
+
+func TestMigrateCharacterIDs(t *testing.T) {
+	client := &memoryDynamo{characters: map[string]bool{}, items: map[string]map[string]*dynamodb.AttributeValue{}}
+	db := &DynamoRepository{client: client}
+	ctx := context.Background()
+	// the same character saved with raw realm names before and after slugs were introduced
+	for _, legacy := range []*model.CharacterInfo{
+		{Region: model.EU, Realm: "Twisting Nether", Name: "Salmond"},
+		{Region: model.EU, Realm: "Soulflayer", Name: "Salmond"},
+		{Region: model.EU, Realm: "soulflayer", Name: "Salmond"},
+	} {
+		id := "legacy:" + legacy.Realm + ":" + legacy.Name
+		item, _ := dynamodbattribute.MarshalMap(&CharacterInfoItem{CharacterInfo: legacy, CharacterID: id, StreamerID: "streamer"})
+		client.characters[id] = true
+		client.items[id] = item
+	}
+
+	slugs := map[string]string{"Twisting Nether": "twisting-nether", "Soulflayer": "soulflayer", "soulflayer": "soulflayer"}
+	migrated, collided, err := db.MigrateCharacterIDs(ctx, func(ctx context.Context, region model.Region, realm string) (string, error) {
+		return slugs[realm], nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if migrated != 2 || collided != 1 {
+		t.Errorf("Migrated %d, collided %d, expected 2 and 1", migrated, collided)
+	}
+	if len(client.characters) != 3 || !client.characters["eu:twisting-nether:salmond"] || !client.characters["eu:soulflayer:salmond"] {
+		t.Errorf("Wrong characters after migration: %v", client.characters)
+	}
+	if client.count == nil || *client.count != 3 {
+		t.Errorf("Counter is not rebuilt after migration")
+	}
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+
+	"github.com/salmondx/wow-twitch-extension/model"
+)
+
+const settingsTable = "STREAMER_SETTINGS"
+
+type SettingsItem struct {
+	*model.ChannelSettings
+	StreamerID string `json:"streamerID"`
+}
+
+func (db *DynamoRepository) GetSettings(ctx context.Context, streamerID string) (*model.ChannelSettings, error) {
+	if streamerID == "" {
+		return nil, errors.New("streamerID can not be empty")
+	}
+
+	resp, err := db.client.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		Key: map[string]*dynamodb.AttributeValue{
+			"streamerID": {
+				S: aws.String(streamerID),
+			},
+		},
+		TableName: aws.String(settingsTable),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Can not get settings of %s, reason: %v", streamerID, err)
+	}
+	settingsItem := &SettingsItem{ChannelSettings: &model.ChannelSettings{}}
+	if resp == nil || len(resp.Item) == 0 {
+		return settingsItem.ChannelSettings, nil
+	}
+	err = dynamodbattribute.UnmarshalMap(resp.Item, settingsItem)
+	if err != nil {
+		return nil, fmt.Errorf("Can not unmarshal result: %v", err)
+	}
+	return settingsItem.ChannelSettings, nil
+}
+
+func (db *DynamoRepository) SaveSettings(ctx context.Context, streamerID string, settings *model.ChannelSettings) error {
+	if streamerID == "" || settings == nil {
+		return errors.New("StreamerID or settings can not be empty")
+	}
+
+	req, err := dynamodbattribute.MarshalMap(SettingsItem{ChannelSettings: settings, StreamerID: streamerID})
+	if err != nil {
+		return fmt.Errorf("Can not marshal settings of %s. Reason: %v", streamerID, err)
+	}
+	_, err = db.client.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		Item:      req,
+		TableName: aws.String(settingsTable),
+	})
+	if err != nil {
+		return fmt.Errorf("Can not save settings of %s. Reason: %v", streamerID, err)
+	}
+	return nil
+}
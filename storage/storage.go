@@ -1,13 +1,32 @@
 package storage
 
-import "github.com/salmondx/wow-twitch-extension/model"
+import (
+	"context"
+
+	"github.com/salmondx/wow-twitch-extension/model"
+)
 
 // CharacterRepository is a permanent storage of a streamer's characters
 type CharacterRepository interface {
-	// List retrieves characters list from database
-	List(streamerID string) ([]*model.CharacterInfo, error)
+	// List retrieves characters list from database in the order of the channel list
+	List(ctx context.Context, streamerID string) ([]*model.CharacterInfo, error)
 	// Add adds new character to database
-	Add(streamerID string, character *model.CharacterInfo) error
+	Add(ctx context.Context, streamerID string, character *model.CharacterInfo) error
+	// AddMany adds characters in a single transaction. Nothing is added if characters don't fit into the limit.
+	// Returned errors are aligned with characters, already stored characters get CharacterDuplicateError.
+	// Characters without Order are put at the end of the list in the given order
+	AddMany(ctx context.Context, streamerID string, characters []*model.CharacterInfo) ([]error, error)
 	// Delete deletes character from database
-	Delete(streamerID, region, realm, name string) error
+	Delete(ctx context.Context, streamerID string, region model.Region, realm, name string) error
+	// DeleteMany deletes characters in a single transaction. Realms are slugs.
+	// Returned errors are aligned with characters, missing characters get CharacterNotFound
+	DeleteMany(ctx context.Context, streamerID string, characters []model.CharacterRef) ([]error, error)
+}
+
+// SettingsRepository is a permanent storage of channel settings
+type SettingsRepository interface {
+	// GetSettings retrieves channel settings. Channels without saved settings get empty settings
+	GetSettings(ctx context.Context, streamerID string) (*model.ChannelSettings, error)
+	// SaveSettings replaces channel settings
+	SaveSettings(ctx context.Context, streamerID string, settings *model.ChannelSettings) error
 }
@@ -0,0 +1,384 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/salmondx/wow-twitch-extension/bnet"
+	"github.com/salmondx/wow-twitch-extension/model"
+	"github.com/salmondx/wow-twitch-extension/service"
+)
+
+// Caller is an owner of the Twitch extension token
+type Caller struct {
+	StreamerID string
+	Role       string
+}
+
+// CharacterRequest is a JSON body of a request to add a character
+type CharacterRequest struct {
+	Region string
+	Realm  string
+	Name   string
+}
+
+// ImportRequest is a JSON body of a request to start account import
+type ImportRequest struct {
+	Region string
+}
+
+// ImportCharactersRequest is a JSON body of a request to add characters picked from account import
+type ImportCharactersRequest struct {
+	Characters []CharacterRequest
+}
+
+// BatchRequest is a JSON body of a request to add and delete characters at once. Deletes are applied first
+type BatchRequest struct {
+	Add    []CharacterRequest
+	Delete []CharacterRequest
+}
+
+// BatchItemResult is an outcome of one character of a batch. Status is the status the single character
+// request would get, Error is set for failed characters
+type BatchItemResult struct {
+	Region string
+	Realm  string
+	Name   string
+	Status int
+	Error  *ErrorMessage `json:",omitempty"`
+}
+
+// BatchResponse lists outcomes in the order of characters in the request
+type BatchResponse struct {
+	Added   []BatchItemResult
+	Deleted []BatchItemResult
+}
+
+// ChannelImportResponse lists outcomes of characters in the order of the export
+type ChannelImportResponse struct {
+	DryRun     bool
+	Characters []BatchItemResult
+	Settings   *model.ChannelSettings `json:",omitempty"`
+}
+
+// endpoint serves one method of a v2 resource
+type endpoint struct {
+	handle      func(ctx context.Context, r *http.Request, caller Caller, characterService service.CharacterService) (interface{}, error)
+	successCode int
+	timeout     time.Duration
+	maxAge      time.Duration
+}
+
+var (
+	wrongChannel      = model.ForbiddenError{"Token doesn't belong to requested channel"}
+	malformedRequest  = model.ValidationError{"Request body is not a valid JSON"}
+	notBroadcaster    = model.ForbiddenError{"Only streamer is allowed to update characters list"}
+	incompleteRequest = model.ValidationError{"Missing required parameters"}
+)
+
+// resourceHandler dispatches v2 requests by method. Every v2 route is scoped by {id} channel,
+// which must be the channel of the token
+func resourceHandler(endpoints map[string]endpoint, characterService service.CharacterService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if preflight(w, r) {
+			return
+		}
+
+		streamerID, role, ok := authenticate(r)
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+
+		e, ok := endpoints[r.Method]
+		if !ok {
+			writeResult(w, r, nil, methodNotAllowed, 0, noCache)
+			return
+		}
+		if r.PathValue("id") != streamerID {
+			writeResult(w, r, nil, wrongChannel, 0, noCache)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), e.timeout)
+		defer cancel()
+
+		data, err := e.handle(ctx, r, Caller{StreamerID: streamerID, Role: role}, characterService)
+		writeResult(w, r, data, err, e.successCode, callerMaxAge(role, e.maxAge))
+	}
+}
+
+// registerV2 registers resource-style API. Returns registered patterns
+func registerV2(mux *http.ServeMux, characterService service.CharacterService) []string {
+	mux.HandleFunc("/v2/channels/{id}/characters", resourceHandler(map[string]endpoint{
+		http.MethodGet:  {listCharacters, http.StatusOK, listTimeout, listMaxAge},
+		http.MethodPost: {addCharacter, http.StatusCreated, updateTimeout, noCache},
+	}, characterService))
+	mux.HandleFunc("/v2/channels/{id}/characters/batch", resourceHandler(map[string]endpoint{
+		http.MethodPost: {batchCharacters, http.StatusOK, listTimeout, noCache},
+	}, characterService))
+	mux.HandleFunc("/v2/channels/{id}/characters/{region}/{realm}/{name}", resourceHandler(map[string]endpoint{
+		http.MethodDelete: {deleteCharacter, http.StatusNoContent, updateTimeout, noCache},
+	}, characterService))
+	mux.HandleFunc("/v2/channels/{id}/characters/{region}/{realm}/{name}/profile", resourceHandler(map[string]endpoint{
+		http.MethodGet: {characterProfile, http.StatusOK, profileTimeout, profileMaxAge},
+	}, characterService))
+	mux.HandleFunc("/v2/channels/{id}/guilds/{region}/{realm}/{name}", resourceHandler(map[string]endpoint{
+		http.MethodGet: {guildProfile, http.StatusOK, profileTimeout, profileMaxAge},
+	}, characterService))
+	mux.HandleFunc("/v2/channels/{id}/export", resourceHandler(map[string]endpoint{
+		http.MethodGet: {exportChannel, http.StatusOK, listTimeout, noCache},
+	}, characterService))
+	mux.HandleFunc("/v2/channels/{id}/channel-import", resourceHandler(map[string]endpoint{
+		http.MethodPost: {importChannel, http.StatusOK, listTimeout, noCache},
+	}, characterService))
+	mux.HandleFunc("/v2/channels/{id}/imports", resourceHandler(map[string]endpoint{
+		http.MethodPost: {startImport, http.StatusCreated, updateTimeout, noCache},
+	}, characterService))
+	mux.HandleFunc("/v2/channels/{id}/imports/{state}", resourceHandler(map[string]endpoint{
+		http.MethodGet: {accountImport, http.StatusOK, updateTimeout, noCache},
+	}, characterService))
+	mux.HandleFunc("/v2/channels/{id}/imports/{state}/characters", resourceHandler(map[string]endpoint{
+		http.MethodPost: {importCharacters, http.StatusCreated, listTimeout, noCache},
+	}, characterService))
+	mux.HandleFunc("/v2/channels/{id}/settings", resourceHandler(map[string]endpoint{
+		http.MethodGet: {channelSettings, http.StatusOK, updateTimeout, noCache},
+		http.MethodPut: {updateSettings, http.StatusNoContent, updateTimeout, noCache},
+	}, characterService))
+	return []string{
+		"/v2/channels/{id}/characters",
+		"/v2/channels/{id}/characters/batch",
+		"/v2/channels/{id}/characters/{region}/{realm}/{name}",
+		"/v2/channels/{id}/characters/{region}/{realm}/{name}/profile",
+		"/v2/channels/{id}/guilds/{region}/{realm}/{name}",
+		"/v2/channels/{id}/export",
+		"/v2/channels/{id}/channel-import",
+		"/v2/channels/{id}/imports",
+		"/v2/channels/{id}/imports/{state}",
+		"/v2/channels/{id}/imports/{state}/characters",
+		"/v2/channels/{id}/settings",
+	}
+}
+
+func listCharacters(ctx context.Context, r *http.Request, caller Caller, characterService service.CharacterService) (interface{}, error) {
+	log.Printf("[INFO] Character list for %s", caller.StreamerID)
+	return characterService.List(ctx, caller.StreamerID)
+}
+
+func addCharacter(ctx context.Context, r *http.Request, caller Caller, characterService service.CharacterService) (interface{}, error) {
+	if caller.Role != "broadcaster" {
+		return nil, notBroadcaster
+	}
+	var character CharacterRequest
+	if err := json.NewDecoder(r.Body).Decode(&character); err != nil {
+		return nil, malformedRequest
+	}
+	if character.Region == "" || character.Realm == "" || character.Name == "" {
+		return nil, incompleteRequest
+	}
+	region, err := model.ParseRegion(character.Region)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("[INFO] Adding character for %s: %s - %s", caller.StreamerID, character.Realm, character.Name)
+	return nil, characterService.Add(ctx, caller.StreamerID, region, character.Realm, character.Name)
+}
+
+func deleteCharacter(ctx context.Context, r *http.Request, caller Caller, characterService service.CharacterService) (interface{}, error) {
+	if caller.Role != "broadcaster" {
+		return nil, notBroadcaster
+	}
+	realm, name := r.PathValue("realm"), r.PathValue("name")
+	region, err := model.ParseRegion(r.PathValue("region"))
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("[INFO] Deleting character for %s: %s - %s", caller.StreamerID, realm, name)
+	return nil, characterService.Delete(ctx, caller.StreamerID, region, realm, name)
+}
+
+func batchCharacters(ctx context.Context, r *http.Request, caller Caller, characterService service.CharacterService) (interface{}, error) {
+	if caller.Role != "broadcaster" {
+		return nil, notBroadcaster
+	}
+	var request BatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return nil, malformedRequest
+	}
+
+	log.Printf("[INFO] Batch for %s: %d to add, %d to delete", caller.StreamerID, len(request.Add), len(request.Delete))
+	deleted, err := characterService.DeleteMany(ctx, caller.StreamerID, characterRefs(request.Delete))
+	if err != nil {
+		return nil, err
+	}
+	toAdd := characterRefs(request.Add)
+	added, err := characterService.AddMany(ctx, caller.StreamerID, toAdd)
+	if err != nil {
+		// deletes are already saved, so they are reported even if no character is added
+		log.Printf("[WARN] Can't add batch characters for %s after deletes. %v", caller.StreamerID, err)
+		added = failedBatch(toAdd, err)
+	}
+	return BatchResponse{
+		Added:   batchItemResults(added, http.StatusCreated),
+		Deleted: batchItemResults(deleted, http.StatusNoContent),
+	}, nil
+}
+
+// characterRefs converts characters of a batch. Regions are validated per character by the service
+func characterRefs(characters []CharacterRequest) []model.CharacterRef {
+	refs := make([]model.CharacterRef, 0, len(characters))
+	for _, character := range characters {
+		region := model.Region(strings.ToLower(strings.TrimSpace(character.Region)))
+		refs = append(refs, model.CharacterRef{Region: region, Realm: character.Realm, Name: character.Name})
+	}
+	return refs
+}
+
+// failedBatch reports the same error for every character of a batch
+func failedBatch(characters []model.CharacterRef, err error) []model.BatchResult {
+	results := make([]model.BatchResult, 0, len(characters))
+	for _, ref := range characters {
+		results = append(results, model.BatchResult{CharacterRef: ref, Err: err})
+	}
+	return results
+}
+
+func batchItemResults(results []model.BatchResult, successCode int) []BatchItemResult {
+	items := make([]BatchItemResult, 0, len(results))
+	for _, result := range results {
+		item := BatchItemResult{
+			Region: string(result.Region),
+			Realm:  result.Realm,
+			Name:   result.Name,
+			Status: successCode,
+		}
+		if result.Err != nil {
+			errorMessage, status, _ := handleError(result.Err)
+			item.Status = status
+			item.Error = &errorMessage
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+func characterProfile(ctx context.Context, r *http.Request, caller Caller, characterService service.CharacterService) (interface{}, error) {
+	realm, name := r.PathValue("realm"), r.PathValue("name")
+	region, err := model.ParseRegion(r.PathValue("region"))
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("[INFO] Profile for %v - %v", realm, name)
+	locale := bnet.ResolveLocale(region, localePreferences(r)...)
+	return characterService.Profile(ctx, caller.StreamerID, region, realm, name, locale)
+}
+
+func guildProfile(ctx context.Context, r *http.Request, caller Caller, characterService service.CharacterService) (interface{}, error) {
+	realm, name := r.PathValue("realm"), r.PathValue("name")
+	region, err := model.ParseRegion(r.PathValue("region"))
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("[INFO] Guild %v - %v", realm, name)
+	locale := bnet.ResolveLocale(region, localePreferences(r)...)
+	return characterService.Guild(ctx, region, realm, name, locale)
+}
+
+func exportChannel(ctx context.Context, r *http.Request, caller Caller, characterService service.CharacterService) (interface{}, error) {
+	if caller.Role != "broadcaster" {
+		return nil, notBroadcaster
+	}
+	log.Printf("[INFO] Exporting channel %s", caller.StreamerID)
+	return characterService.ExportChannel(ctx, caller.StreamerID)
+}
+
+func importChannel(ctx context.Context, r *http.Request, caller Caller, characterService service.CharacterService) (interface{}, error) {
+	if caller.Role != "broadcaster" {
+		return nil, notBroadcaster
+	}
+	var export model.ChannelExport
+	if err := json.NewDecoder(r.Body).Decode(&export); err != nil {
+		return nil, malformedRequest
+	}
+	dryRun := r.URL.Query().Get("dryRun") == "true"
+
+	channelImport, err := characterService.ImportChannel(ctx, caller.StreamerID, &export, dryRun)
+	if err != nil {
+		return nil, err
+	}
+	return ChannelImportResponse{
+		DryRun:     channelImport.DryRun,
+		Characters: batchItemResults(channelImport.Characters, http.StatusCreated),
+		Settings:   channelImport.Settings,
+	}, nil
+}
+
+func startImport(ctx context.Context, r *http.Request, caller Caller, characterService service.CharacterService) (interface{}, error) {
+	if caller.Role != "broadcaster" {
+		return nil, notBroadcaster
+	}
+	var request ImportRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return nil, malformedRequest
+	}
+	region, err := model.ParseRegion(request.Region)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("[INFO] Starting account import for %s in %s", caller.StreamerID, region)
+	return characterService.StartImport(ctx, caller.StreamerID, region)
+}
+
+func accountImport(ctx context.Context, r *http.Request, caller Caller, characterService service.CharacterService) (interface{}, error) {
+	if caller.Role != "broadcaster" {
+		return nil, notBroadcaster
+	}
+	return characterService.Import(ctx, caller.StreamerID, r.PathValue("state"))
+}
+
+func importCharacters(ctx context.Context, r *http.Request, caller Caller, characterService service.CharacterService) (interface{}, error) {
+	if caller.Role != "broadcaster" {
+		return nil, notBroadcaster
+	}
+	var request ImportCharactersRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return nil, malformedRequest
+	}
+	picked := make([]model.CharacterRef, 0, len(request.Characters))
+	for _, character := range request.Characters {
+		region, err := model.ParseRegion(character.Region)
+		if err != nil {
+			return nil, err
+		}
+		picked = append(picked, model.CharacterRef{Region: region, Realm: character.Realm, Name: character.Name})
+	}
+	return characterService.ImportCharacters(ctx, caller.StreamerID, r.PathValue("state"), picked)
+}
+
+func channelSettings(ctx context.Context, r *http.Request, caller Caller, characterService service.CharacterService) (interface{}, error) {
+	return characterService.Settings(ctx, caller.StreamerID)
+}
+
+func updateSettings(ctx context.Context, r *http.Request, caller Caller, characterService service.CharacterService) (interface{}, error) {
+	if caller.Role != "broadcaster" {
+		return nil, notBroadcaster
+	}
+	var settings model.ChannelSettings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		return nil, malformedRequest
+	}
+
+	log.Printf("[INFO] Updating settings of %s", caller.StreamerID)
+	return nil, characterService.UpdateSettings(ctx, caller.StreamerID, &settings)
+}
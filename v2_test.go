@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/salmondx/wow-twitch-extension/model"
+)
+
+type stubService struct {
+	calls      []string
+	addManyErr error
+}
+
+func (s *stubService) List(ctx context.Context, streamerID string) ([]*model.CharacterInfo, error) {
+	s.calls = append(s.calls, "list "+streamerID)
+	return []*model.CharacterInfo{}, nil
+}
+
+func (s *stubService) Add(ctx context.Context, streamerID string, region model.Region, realm, name string) error {
+	s.calls = append(s.calls, "add "+streamerID+" "+string(region)+":"+realm+":"+name)
+	return nil
+}
+
+func (s *stubService) Delete(ctx context.Context, streamerID string, region model.Region, realm, name string) error {
+	s.calls = append(s.calls, "delete "+streamerID+" "+string(region)+":"+realm+":"+name)
+	return nil
+}
+
+func (s *stubService) Profile(ctx context.Context, streamerID string, region model.Region, realm, name, locale string) (*model.Character, error) {
+	s.calls = append(s.calls, "profile "+streamerID+" "+string(region)+":"+realm+":"+name+" "+locale)
+	return &model.Character{Name: name}, nil
+}
+
+func (s *stubService) Settings(ctx context.Context, streamerID string) (*model.ChannelSettings, error) {
+	s.calls = append(s.calls, "settings "+streamerID)
+	return &model.ChannelSettings{TooltipProvider: "wowhead"}, nil
+}
+
+func (s *stubService) UpdateSettings(ctx context.Context, streamerID string, settings *model.ChannelSettings) error {
+	s.calls = append(s.calls, "update settings "+streamerID+" "+settings.TooltipProvider)
+	return nil
+}
+
+func (s *stubService) Guild(ctx context.Context, region model.Region, realm, name, locale string) (*model.Guild, error) {
+	s.calls = append(s.calls, "guild "+string(region)+":"+realm+":"+name+" "+locale)
+	return &model.Guild{Name: name}, nil
+}
+
+func (s *stubService) StartImport(ctx context.Context, streamerID string, region model.Region) (*model.AccountImport, error) {
+	s.calls = append(s.calls, "start import "+streamerID+" "+string(region))
+	return &model.AccountImport{State: "state", Region: region, Status: model.ImportPending}, nil
+}
+
+func (s *stubService) CompleteImport(ctx context.Context, state, code string) error {
+	s.calls = append(s.calls, "complete import "+state+" "+code)
+	return nil
+}
+
+func (s *stubService) Import(ctx context.Context, streamerID, state string) (*model.AccountImport, error) {
+	s.calls = append(s.calls, "import "+streamerID+" "+state)
+	return &model.AccountImport{State: state, Status: model.ImportReady}, nil
+}
+
+func (s *stubService) ImportCharacters(ctx context.Context, streamerID, state string, picked []model.CharacterRef) ([]*model.CharacterInfo, error) {
+	call := "import characters " + streamerID + " " + state
+	for _, ref := range picked {
+		call += " " + string(ref.Region) + ":" + ref.Realm + ":" + ref.Name
+	}
+	s.calls = append(s.calls, call)
+	return []*model.CharacterInfo{}, nil
+}
+
+func (s *stubService) AddMany(ctx context.Context, streamerID string, characters []model.CharacterRef) ([]model.BatchResult, error) {
+	results := s.batch("add many "+streamerID, characters)
+	if s.addManyErr != nil {
+		return nil, s.addManyErr
+	}
+	return results, nil
+}
+
+func (s *stubService) DeleteMany(ctx context.Context, streamerID string, characters []model.CharacterRef) ([]model.BatchResult, error) {
+	return s.batch("delete many "+streamerID, characters), nil
+}
+
+func (s *stubService) ExportChannel(ctx context.Context, streamerID string) (*model.ChannelExport, error) {
+	s.calls = append(s.calls, "export "+streamerID)
+	return &model.ChannelExport{Version: model.ExportVersion}, nil
+}
+
+func (s *stubService) ImportChannel(ctx context.Context, streamerID string, export *model.ChannelExport, dryRun bool) (*model.ChannelImport, error) {
+	refs := make([]model.CharacterRef, 0, len(export.Characters))
+	for _, character := range export.Characters {
+		refs = append(refs, model.CharacterRef{Region: character.Region, Realm: character.RealmSlug, Name: character.Name})
+	}
+	results := s.batch(fmt.Sprintf("import channel %s %t", streamerID, dryRun), refs)
+	return &model.ChannelImport{DryRun: dryRun, Characters: results}, nil
+}
+
+func (s *stubService) batch(call string, characters []model.CharacterRef) []model.BatchResult {
+	results := make([]model.BatchResult, 0, len(characters))
+	for _, ref := range characters {
+		call += " " + string(ref.Region) + ":" + ref.Realm + ":" + ref.Name
+		results = append(results, model.BatchResult{CharacterRef: ref})
+	}
+	s.calls = append(s.calls, call)
+	return results
+}
+
+func TestV2Routes(t *testing.T) {
+	stage = StageDev
+	var tests = []struct {
+		method string
+		path   string
+		body   string
+		status int
+		call   string
+	}{
+		{http.MethodGet, "/v2/channels/testing_streamer/characters", "", http.StatusOK, "list testing_streamer"},
+		{http.MethodPost, "/v2/channels/testing_streamer/characters", `{"region":"eu","realm":"Twisting Nether","name":"Salmond"}`, http.StatusCreated, "add testing_streamer eu:Twisting Nether:Salmond"},
+		{http.MethodPost, "/v2/channels/testing_streamer/characters", `{"region":"eu"}`, http.StatusBadRequest, ""},
+		{http.MethodPost, "/v2/channels/testing_streamer/characters", `region=eu`, http.StatusBadRequest, ""},
+		{http.MethodDelete, "/v2/channels/testing_streamer/characters/eu/Twisting%20Nether/Salmond", "", http.StatusNoContent, "delete testing_streamer eu:Twisting Nether:Salmond"},
+		{http.MethodGet, "/v2/channels/testing_streamer/characters/eu/Soulflayer/Salmond/profile", "", http.StatusOK, "profile testing_streamer eu:Soulflayer:Salmond en_GB"},
+		{http.MethodGet, "/v2/channels/testing_streamer/characters/EU/Soulflayer/Salmond/profile", "", http.StatusOK, "profile testing_streamer eu:Soulflayer:Salmond en_GB"},
+		{http.MethodGet, "/v2/channels/testing_streamer/characters/eu/Soulflayer/Salmond/profile?locale=de-DE", "", http.StatusOK, "profile testing_streamer eu:Soulflayer:Salmond de_DE"},
+		{http.MethodGet, "/v2/channels/testing_streamer/characters/xx/Soulflayer/Salmond/profile", "", http.StatusBadRequest, ""},
+		{http.MethodPost, "/v2/channels/testing_streamer/characters", `{"region":"sea","realm":"Soulflayer","name":"Salmond"}`, http.StatusBadRequest, ""},
+		{http.MethodGet, "/v2/channels/testing_streamer/guilds/eu/Soulflayer/Method%20Raid/profile", "", http.StatusNotFound, ""},
+		{http.MethodGet, "/v2/channels/testing_streamer/guilds/eu/Soulflayer/Method%20Raid?locale=fr-FR", "", http.StatusOK, "guild eu:Soulflayer:Method Raid fr_FR"},
+		{http.MethodPost, "/v2/channels/testing_streamer/characters/batch", `{"Add":[{"Region":"EU","Realm":"Soulflayer","Name":"Salmond"}],"Delete":[{"Region":"eu","Realm":"Soulflayer","Name":"Old"}]}`, http.StatusOK, "delete many testing_streamer eu:Soulflayer:Old"},
+		{http.MethodPost, "/v2/channels/testing_streamer/characters/batch", `[]`, http.StatusBadRequest, ""},
+		{http.MethodGet, "/v2/channels/testing_streamer/export", "", http.StatusOK, "export testing_streamer"},
+		{http.MethodPost, "/v2/channels/testing_streamer/channel-import?dryRun=true", `{"Version":1,"Characters":[{"Region":"eu","RealmSlug":"soulflayer","Name":"Salmond"}]}`, http.StatusOK, "import channel testing_streamer true eu:soulflayer:Salmond"},
+		{http.MethodPost, "/v2/channels/testing_streamer/channel-import", `{"Characters":[]}`, http.StatusOK, "import channel testing_streamer false"},
+		{http.MethodPost, "/v2/channels/testing_streamer/imports", `{"Region":"EU"}`, http.StatusCreated, "start import testing_streamer eu"},
+		{http.MethodPost, "/v2/channels/testing_streamer/imports", `{"Region":"sea"}`, http.StatusBadRequest, ""},
+		{http.MethodGet, "/v2/channels/testing_streamer/imports/abc", "", http.StatusOK, "import testing_streamer abc"},
+		{http.MethodPost, "/v2/channels/testing_streamer/imports/abc/characters", `{"Characters":[{"Region":"eu","Realm":"soulflayer","Name":"Salmond"}]}`, http.StatusCreated, "import characters testing_streamer abc eu:soulflayer:Salmond"},
+		{http.MethodPost, "/v2/channels/testing_streamer/imports/abc/characters", `[]`, http.StatusBadRequest, ""},
+		{http.MethodGet, "/v2/channels/testing_streamer/settings", "", http.StatusOK, "settings testing_streamer"},
+		{http.MethodPut, "/v2/channels/testing_streamer/settings", `{"TooltipProvider":"wowhead-classic"}`, http.StatusNoContent, "update settings testing_streamer wowhead-classic"},
+		{http.MethodPut, "/v2/channels/testing_streamer/settings", `wowhead`, http.StatusBadRequest, ""},
+		{http.MethodGet, "/v2/channels/other/characters", "", http.StatusForbidden, ""},
+		{http.MethodPut, "/v2/channels/testing_streamer/characters", "", http.StatusMethodNotAllowed, ""},
+	}
+
+	for _, tt := range tests {
+		characterService := &stubService{}
+		mux := http.NewServeMux()
+		registerV2(mux, characterService)
+
+		r := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		r.Header.Set("Authorization", "token")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+
+		if w.Code != tt.status {
+			t.Errorf("%s %s: status %d, expected %d", tt.method, tt.path, w.Code, tt.status)
+		}
+		call := ""
+		if len(characterService.calls) > 0 {
+			call = characterService.calls[0]
+		}
+		if call != tt.call {
+			t.Errorf("%s %s: called %q, expected %q", tt.method, tt.path, call, tt.call)
+		}
+	}
+}
+
+func TestBatchFailedAdd(t *testing.T) {
+	stage = StageDev
+	characterService := &stubService{addManyErr: model.UpstreamUnavailableError{S: "Battle.Net is unavailable"}}
+	mux := http.NewServeMux()
+	registerV2(mux, characterService)
+
+	body := `{"Add":[{"Region":"eu","Realm":"Soulflayer","Name":"Salmond"}],"Delete":[{"Region":"eu","Realm":"Soulflayer","Name":"Old"}]}`
+	r := httptest.NewRequest(http.MethodPost, "/v2/channels/testing_streamer/characters/batch", strings.NewReader(body))
+	r.Header.Set("Authorization", "token")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Status %d, expected %d", w.Code, http.StatusOK)
+	}
+	var response BatchResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Deleted) != 1 || response.Deleted[0].Status != http.StatusNoContent {
+		t.Errorf("Deletes are not reported: %v", response.Deleted)
+	}
+	if len(response.Added) != 1 || response.Added[0].Status != http.StatusServiceUnavailable {
+		t.Errorf("Failed add is not reported: %v", response.Added)
+	}
+}
+
+func TestBatchItemResults(t *testing.T) {
+	results := []model.BatchResult{
+		{CharacterRef: model.CharacterRef{Region: model.EU, Realm: "Soulflayer", Name: "Salmond"}},
+		{CharacterRef: model.CharacterRef{Region: model.EU, Realm: "Soulflayer", Name: "Missing"}, Err: model.CharacterNotFound{"Character not found"}},
+		{CharacterRef: model.CharacterRef{Region: model.EU, Realm: "Soulflayer", Name: "Extra"}, Err: model.CharacterLimitError{"Limit is 20"}},
+	}
+	items := batchItemResults(results, http.StatusCreated)
+
+	expected := []struct {
+		status int
+		code   int
+	}{
+		{http.StatusCreated, 0},
+		{http.StatusNotFound, characterNotFound.Code},
+		{http.StatusConflict, characterLimit.Code},
+	}
+	for i, e := range expected {
+		if items[i].Status != e.status {
+			t.Errorf("%s: status %d, expected %d", items[i].Name, items[i].Status, e.status)
+		}
+		code := 0
+		if items[i].Error != nil {
+			code = items[i].Error.Code
+		}
+		if code != e.code {
+			t.Errorf("%s: code %d, expected %d", items[i].Name, code, e.code)
+		}
+	}
+}